@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/GoFurry/gofurry-user/apps/idp/models"
+	"github.com/GoFurry/gofurry-user/apps/idp/service"
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+ * @Desc: OAuth 2.1 / OpenID Connect 身份提供方
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+type idpApi struct{}
+
+var IdpApi *idpApi
+
+func init() {
+	IdpApi = &idpApi{}
+}
+
+// @Summary OIDC 发现文档
+// @Schemes
+// @Description OpenID Connect Discovery 1.0
+// @Tags Idp
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /.well-known/openid-configuration [Get]
+func (api *idpApi) Discovery(c *fiber.Ctx) error {
+	discovery, err := service.GetIdpService().Discovery()
+	if err != nil {
+		return oauthError(c, &models.OauthError{Status: http.StatusNotFound, Error: "not_found", Description: err.Error()})
+	}
+	return c.JSON(discovery)
+}
+
+// @Summary JWKS 公钥
+// @Schemes
+// @Description ID Token 与访问令牌的验签公钥
+// @Tags Idp
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /oauth2/jwks [Get]
+func (api *idpApi) Jwks(c *fiber.Ctx) error {
+	jwks, err := service.GetIdpService().Jwks()
+	if err != nil {
+		return oauthError(c, &models.OauthError{Status: http.StatusInternalServerError, Error: "server_error", Description: err.Error()})
+	}
+	return c.JSON(jwks)
+}
+
+// @Summary 授权端点
+// @Schemes
+// @Description 授权码 + PKCE(S256), 复用当前登录会话
+// @Tags Idp
+// @Param response_type query string true "code"
+// @Param client_id query string true "客户端标识"
+// @Param redirect_uri query string true "回调地址"
+// @Param scope query string false "scope"
+// @Param state query string false "state"
+// @Param nonce query string false "nonce"
+// @Param code_challenge query string true "PKCE challenge"
+// @Param code_challenge_method query string true "S256"
+// @Success 302
+// @Router /oauth2/authorize [Get]
+func (api *idpApi) Authorize(c *fiber.Ctx) error {
+	var req models.AuthorizeRequest
+	if err := c.QueryParser(&req); err != nil {
+		return oauthError(c, &models.OauthError{Status: http.StatusBadRequest, Error: "invalid_request", Description: err.Error()})
+	}
+	redirect, oerr := service.GetIdpService().Authorize(c, req)
+	if oerr != nil {
+		return oauthError(c, oerr)
+	}
+	return c.Redirect(redirect, http.StatusFound)
+}
+
+// @Summary 令牌端点
+// @Schemes
+// @Description authorization_code 与 refresh_token
+// @Tags Idp
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Success 200 {object} models.TokenResponse
+// @Router /oauth2/token [Post]
+func (api *idpApi) Token(c *fiber.Ctx) error {
+	var req models.TokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return oauthError(c, &models.OauthError{Status: http.StatusBadRequest, Error: "invalid_request", Description: err.Error()})
+	}
+	resp, oerr := service.GetIdpService().Token(c, req)
+	if oerr != nil {
+		return oauthError(c, oerr)
+	}
+	noStore(c)
+	return c.JSON(resp)
+}
+
+// @Summary 用户信息端点
+// @Schemes
+// @Description OIDC UserInfo
+// @Tags Idp
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /oauth2/userinfo [Get]
+func (api *idpApi) UserInfo(c *fiber.Ctx) error {
+	token, ok := bearerToken(c)
+	if !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return oauthError(c, &models.OauthError{Status: http.StatusUnauthorized, Error: "invalid_token", Description: "缺少访问令牌"})
+	}
+	info, oerr := service.GetIdpService().UserInfo(c, token)
+	if oerr != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="`+oerr.Error+`"`)
+		return oauthError(c, oerr)
+	}
+	noStore(c)
+	return c.JSON(info)
+}
+
+// @Summary 撤销端点
+// @Schemes
+// @Description RFC 7009 令牌撤销, 机密客户端可撤销登录凭证
+// @Tags Idp
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Success 200
+// @Router /oauth2/revoke [Post]
+func (api *idpApi) Revoke(c *fiber.Ctx) error {
+	var req models.RevokeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return oauthError(c, &models.OauthError{Status: http.StatusBadRequest, Error: "invalid_request", Description: err.Error()})
+	}
+	if oerr := service.GetIdpService().Revoke(c, req); oerr != nil {
+		return oauthError(c, oerr)
+	}
+	return c.SendStatus(http.StatusOK)
+}
+
+// @Summary 自省端点
+// @Schemes
+// @Description RFC 7662 令牌自省, 需机密客户端认证
+// @Tags Idp
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Success 200 {object} models.IntrospectResponse
+// @Router /oauth2/introspect [Post]
+func (api *idpApi) Introspect(c *fiber.Ctx) error {
+	var req models.IntrospectRequest
+	if err := c.BodyParser(&req); err != nil {
+		return oauthError(c, &models.OauthError{Status: http.StatusBadRequest, Error: "invalid_request", Description: err.Error()})
+	}
+	resp, oerr := service.GetIdpService().Introspect(c, req)
+	if oerr != nil {
+		return oauthError(c, oerr)
+	}
+	noStore(c)
+	return c.JSON(resp)
+}
+
+// 读取 Bearer 令牌
+func bearerToken(c *fiber.Ctx) (string, bool) {
+	auth := strings.TrimSpace(c.Get(fiber.HeaderAuthorization))
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:]), true
+	}
+	return "", false
+}
+
+// 令牌响应禁止缓存
+func noStore(c *fiber.Ctx) {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+}
+
+// 标准错误响应 不使用 common.ResultData 包装
+func oauthError(c *fiber.Ctx, oerr *models.OauthError) error {
+	if oerr.Status == http.StatusUnauthorized && oerr.Error == "invalid_client" {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="`+common.COMMON_PROJECT_NAME+`"`)
+	}
+	noStore(c)
+	return c.Status(oerr.Status).JSON(oerr)
+}
@@ -0,0 +1,34 @@
+package dao
+
+import (
+	"errors"
+
+	"github.com/GoFurry/gofurry-user/apps/idp/models"
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/abstract"
+	"gorm.io/gorm"
+)
+
+var newClientDao = new(clientDao)
+
+func init() {
+	newClientDao.Init()
+	newClientDao.Mode = models.GfOauthClient{}
+}
+
+type clientDao struct{ abstract.Dao }
+
+func GetClientDao() *clientDao { return newClientDao }
+
+// FindOneByClientId 按 client_id 查询客户端 客户端由 CLI 维护 查询走只读副本
+func (dao *clientDao) FindOneByClientId(clientId string) (record models.GfOauthClient, err common.GFError) {
+	db := dao.Replica().Table(models.TableNameGfOauthClient).Where("client_id = ?", clientId).Take(&record)
+	if err := db.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return record, common.NewDaoError(common.RETURN_RECORD_NOT_FOUND)
+		} else {
+			return record, common.NewDaoError(err.Error())
+		}
+	}
+	return
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/GoFurry/gofurry-user/common/abstract"
+	cm "github.com/GoFurry/gofurry-user/common/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const TableNameGfOauthClient = "gf_oauth_client"
+
+// GfOauthClient mapped from table <gf_oauth_client>
+type GfOauthClient struct {
+	abstract.DefaultModel
+	ClientID     string       `gorm:"column:client_id;type:character varying(64);not null;uniqueIndex:uk_gf_oauth_client_client_id;comment:客户端标识" json:"clientId"` // 客户端标识
+	ClientSecret string       `gorm:"column:client_secret;type:character varying(128);comment:客户端密钥摘要" json:"-"`                                                   // 客户端密钥摘要 公开客户端为空
+	RedirectUris string       `gorm:"column:redirect_uris;type:text;not null;comment:回调地址 空格分隔" json:"redirectUris"`                                               // 回调地址 空格分隔
+	Scopes       string       `gorm:"column:scopes;type:character varying(255);not null;comment:允许的scope 空格分隔" json:"scopes"`                                      // 允许的scope 空格分隔
+	Public       bool         `gorm:"column:public;type:boolean;not null;comment:是否公开客户端" json:"public"`                                                           // 是否公开客户端
+	Trusted      bool         `gorm:"column:trusted;type:boolean;not null;comment:是否可信客户端" json:"trusted"`                                                         // 是否可信客户端 可信客户端无需用户确认
+	Status       string       `gorm:"column:status;type:character varying(20);not null;comment:客户端状态" json:"status"`                                               // 客户端状态
+	CreateTime   cm.LocalTime `gorm:"column:create_time;type:timestamp;not null;autoCreateTime;comment:创建时间" json:"createTime"`                                    // 创建时间
+	UpdateTime   cm.LocalTime `gorm:"column:update_time;type:timestamp;not null;autoUpdateTime;comment:更新时间" json:"updateTime"`                                    // 更新时间
+}
+
+// TableName GfOauthClient's table name
+func (*GfOauthClient) TableName() string {
+	return TableNameGfOauthClient
+}
+
+// 回调地址是否已登记 精确匹配
+func (client *GfOauthClient) HasRedirectUri(redirectUri string) bool {
+	for _, uri := range strings.Fields(client.RedirectUris) {
+		if uri == redirectUri {
+			return true
+		}
+	}
+	return false
+}
+
+// 请求的 scope 是否均在允许范围内
+func (client *GfOauthClient) AllowScopes(scopes []string) bool {
+	allowed := strings.Fields(client.Scopes)
+	for _, scope := range scopes {
+		found := false
+		for _, a := range allowed {
+			if a == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// AuthCode 授权码上下文 存 redis
+type AuthCode struct {
+	ClientId            string `json:"clientId"`
+	RedirectUri         string `json:"redirectUri"`
+	UserId              int64  `json:"userId,string"`
+	Scope               string `json:"scope"`
+	Nonce               string `json:"nonce"`
+	CodeChallenge       string `json:"codeChallenge"`
+	CodeChallengeMethod string `json:"codeChallengeMethod"`
+	AuthTime            int64  `json:"authTime"`
+}
+
+// RefreshToken 刷新令牌上下文 存 redis
+type RefreshToken struct {
+	ClientId string `json:"clientId"`
+	UserId   int64  `json:"userId,string"`
+	Scope    string `json:"scope"`
+	AuthTime int64  `json:"authTime"`
+}
+
+// AccessClaims 访问令牌
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	ClientId string `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+// IdTokenClaims OIDC ID Token
+type IdTokenClaims struct {
+	jwt.RegisteredClaims
+	AuthTime      int64  `json:"auth_time,omitempty"`
+	Nonce         string `json:"nonce,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Nickname      string `json:"nickname,omitempty"`
+	Picture       string `json:"picture,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+// TokenRequest 令牌端点表单
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectUri  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientId     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// TokenResponse 令牌端点响应 RFC 6749 5.1
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OauthError 标准错误响应 RFC 6749 5.2
+type OauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+// CreateClientRequest 登记客户端
+type CreateClientRequest struct {
+	Name         string `json:"name" validate:"required"`
+	RedirectUris string `json:"redirectUris" validate:"required"`
+	Scopes       string `json:"scopes" validate:"required"`
+	Public       bool   `json:"public"`
+	Trusted      bool   `json:"trusted"`
+}
+
+// AuthorizeRequest 授权端点参数
+type AuthorizeRequest struct {
+	ResponseType        string `query:"response_type"`
+	ClientId            string `query:"client_id"`
+	RedirectUri         string `query:"redirect_uri"`
+	Scope               string `query:"scope"`
+	State               string `query:"state"`
+	Nonce               string `query:"nonce"`
+	Prompt              string `query:"prompt"`
+	CodeChallenge       string `query:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method"`
+}
+
+// RevokeRequest 撤销端点表单 RFC 7009
+type RevokeRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientId      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// IntrospectRequest 自省端点表单 RFC 7662
+type IntrospectRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientId      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// IntrospectResponse 自省响应 令牌无效时仅返回 active=false
+type IntrospectResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
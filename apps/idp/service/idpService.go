@@ -0,0 +1,533 @@
+package service
+
+/*
+ * @Desc: OAuth 2.1 / OpenID Connect 身份提供方
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/apps/idp/dao"
+	"github.com/GoFurry/gofurry-user/apps/idp/models"
+	ud "github.com/GoFurry/gofurry-user/apps/user/dao"
+	um "github.com/GoFurry/gofurry-user/apps/user/models"
+	us "github.com/GoFurry/gofurry-user/apps/user/service"
+	"github.com/GoFurry/gofurry-user/common"
+	ca "github.com/GoFurry/gofurry-user/common/abstract"
+	"github.com/GoFurry/gofurry-user/common/log"
+	cm "github.com/GoFurry/gofurry-user/common/models"
+	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type idpService struct{}
+
+var idpSingleton = new(idpService)
+
+func GetIdpService() *idpService { return idpSingleton }
+
+const (
+	codePrefix    = "idp:code:"
+	refreshPrefix = "idp:rt:"
+	revokedPrefix = "idp:revoked:"
+)
+
+// 支持的 scope
+var supportedScopes = []string{"openid", "profile", "email", "offline_access"}
+
+func newOauthError(status int, code string, desc string) *models.OauthError {
+	return &models.OauthError{Status: status, Error: code, Description: desc}
+}
+
+// Discovery OIDC 发现文档 未配置签发者时返回错误
+func (svc *idpService) Discovery() (map[string]any, error) {
+	iss := issuer()
+	if iss == "" {
+		return nil, errors.New("IdP未配置签发者")
+	}
+	return map[string]any{
+		"issuer":                 iss,
+		"authorization_endpoint": iss + "/oauth2/authorize",
+		"token_endpoint":         iss + "/oauth2/token",
+		"userinfo_endpoint":      iss + "/oauth2/userinfo",
+		"revocation_endpoint":    iss + "/oauth2/revoke",
+		"introspection_endpoint": iss + "/oauth2/introspect",
+		"introspection_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"jwks_uri":                                       iss + "/oauth2/jwks",
+		"response_types_supported":                       []string{"code"},
+		"response_modes_supported":                       []string{"query"},
+		"grant_types_supported":                          []string{"authorization_code", "refresh_token"},
+		"subject_types_supported":                        []string{"public"},
+		"id_token_signing_alg_values_supported":          []string{jwt.SigningMethodRS256.Alg()},
+		"scopes_supported":                               supportedScopes,
+		"token_endpoint_auth_methods_supported":          []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":               []string{"S256"},
+		"claims_supported":                               []string{"sub", "name", "nickname", "picture", "email", "email_verified", "auth_time", "nonce"},
+		"authorization_response_iss_parameter_supported": true,
+	}, nil
+}
+
+// Authorize 授权端点 返回跳转地址
+// 客户端或回调地址无效时不跳转 直接返回错误
+func (svc *idpService) Authorize(c *fiber.Ctx, req models.AuthorizeRequest) (string, *models.OauthError) {
+	client, err := dao.GetClientDao().FindOneByClientId(req.ClientId)
+	if err != nil || client.Status != "normal" {
+		return "", newOauthError(http.StatusBadRequest, "invalid_request", "client_id无效")
+	}
+	if req.RedirectUri == "" || !client.HasRedirectUri(req.RedirectUri) {
+		return "", newOauthError(http.StatusBadRequest, "invalid_request", "redirect_uri未登记")
+	}
+
+	// 以下错误通过回调地址告知客户端
+	fail := func(code string, desc string) (string, *models.OauthError) {
+		return withQuery(req.RedirectUri, map[string]string{
+			"error":             code,
+			"error_description": desc,
+			"state":             req.State,
+			"iss":               issuer(),
+		}), nil
+	}
+	if req.ResponseType != "code" {
+		return fail("unsupported_response_type", "仅支持 response_type=code")
+	}
+	// OAuth 2.1 授权码必须配合 PKCE
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return fail("invalid_request", "须使用 PKCE S256")
+	}
+	scopes := strings.Fields(req.Scope)
+	if !client.AllowScopes(scopes) {
+		return fail("invalid_scope", "scope超出客户端允许范围")
+	}
+
+	// 尚无授权确认页 仅可信客户端可复用登录会话直接签发授权码
+	if !client.Trusted {
+		return fail("consent_required", "客户端未标记为可信")
+	}
+
+	// 复用现有登录会话
+	claims, gfsErr := us.GetUserService().ValidateSessionToken(c.UserContext(), util.GetAuthToken(c))
+	if gfsErr != nil || req.Prompt == "login" {
+		if req.Prompt == "none" {
+			return fail("login_required", "用户未登录")
+		}
+		loginUrl := env.GetServerConfig().Idp.LoginUrl
+		if loginUrl == "" {
+			return fail("login_required", "用户未登录")
+		}
+		// 登录后回到授权端点 基于签发者拼接 不信任 Host 头
+		// 去掉 prompt 否则 prompt=login 会再次跳转登录
+		authorizeUrl := issuer() + "/oauth2/authorize?" + string(c.Request().URI().QueryString())
+		return withQuery(loginUrl, map[string]string{"redirect_to": withoutQuery(authorizeUrl, "prompt")}), nil
+	}
+	userId, parseErr := util.String2Int64(claims.UserId)
+	if parseErr != nil {
+		return fail("server_error", "登录信息有误")
+	}
+	user, gfsErr := findActiveUser(c.UserContext(), userId)
+	if gfsErr != nil {
+		return fail("access_denied", gfsErr.GetMsg())
+	}
+	authTime := time.Now().Unix()
+	if claims.IssuedAt != nil {
+		authTime = claims.IssuedAt.Unix()
+	}
+
+	// 生成一次性授权码
+	code := util.GenerateSecureToken(32)
+	value, _ := sonic.MarshalString(models.AuthCode{
+		ClientId:            client.ClientID,
+		RedirectUri:         req.RedirectUri,
+		UserId:              user.ID,
+		Scope:               strings.Join(scopes, " "),
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthTime:            authTime,
+	})
+	if gfsErr = cs.SetExpireCtx(c.UserContext(), codePrefix+code, value, ttlOrDefault(env.GetServerConfig().Idp.CodeTTL, time.Minute)); gfsErr != nil {
+		return fail("server_error", "授权码存储失败")
+	}
+
+	return withQuery(req.RedirectUri, map[string]string{
+		"code":  code,
+		"state": req.State,
+		"iss":   issuer(),
+	}), nil
+}
+
+// Token 令牌端点
+func (svc *idpService) Token(c *fiber.Ctx, req models.TokenRequest) (*models.TokenResponse, *models.OauthError) {
+	client, oerr := authenticateClient(c, req.ClientId, req.ClientSecret)
+	if oerr != nil {
+		return nil, oerr
+	}
+
+	switch req.GrantType {
+	case "authorization_code":
+		value, gfsErr := cs.GetDelStringCtx(c.UserContext(), codePrefix+req.Code)
+		if gfsErr != nil || value == "" {
+			return nil, newOauthError(http.StatusBadRequest, "invalid_grant", "授权码无效或已使用")
+		}
+		var code models.AuthCode
+		if err := sonic.UnmarshalString(value, &code); err != nil {
+			return nil, newOauthError(http.StatusBadRequest, "invalid_grant", "授权码无效或已使用")
+		}
+		if code.ClientId != client.ClientID || code.RedirectUri != req.RedirectUri {
+			return nil, newOauthError(http.StatusBadRequest, "invalid_grant", "授权码与客户端不匹配")
+		}
+		if !verifyPKCE(code.CodeChallenge, req.CodeVerifier) {
+			return nil, newOauthError(http.StatusBadRequest, "invalid_grant", "code_verifier校验失败")
+		}
+		return issueTokens(c, client, code.UserId, code.Scope, code.Nonce, code.AuthTime)
+
+	case "refresh_token":
+		value, gfsErr := cs.GetDelStringCtx(c.UserContext(), refreshPrefix+util.CreateSHA256(req.RefreshToken))
+		if gfsErr != nil || value == "" {
+			return nil, newOauthError(http.StatusBadRequest, "invalid_grant", "刷新令牌无效或已过期")
+		}
+		var refresh models.RefreshToken
+		if err := sonic.UnmarshalString(value, &refresh); err != nil || refresh.ClientId != client.ClientID {
+			return nil, newOauthError(http.StatusBadRequest, "invalid_grant", "刷新令牌无效或已过期")
+		}
+		scope := refresh.Scope
+		if req.Scope != "" {
+			granted := strings.Fields(refresh.Scope)
+			for _, s := range strings.Fields(req.Scope) {
+				if !util.In(s, granted) {
+					return nil, newOauthError(http.StatusBadRequest, "invalid_scope", "scope超出原授权范围")
+				}
+			}
+			scope = req.Scope
+		}
+		return issueTokens(c, client, refresh.UserId, scope, "", refresh.AuthTime)
+	}
+	return nil, newOauthError(http.StatusBadRequest, "unsupported_grant_type", "不支持的grant_type")
+}
+
+// UserInfo 用户信息端点
+func (svc *idpService) UserInfo(c *fiber.Ctx, accessToken string) (map[string]any, *models.OauthError) {
+	claims, err := parseAccessToken(c, accessToken)
+	if err != nil || isRevoked(c.UserContext(), claims.ID) {
+		return nil, newOauthError(http.StatusUnauthorized, "invalid_token", "访问令牌无效")
+	}
+	scopes := strings.Fields(claims.Scope)
+	if !util.In("openid", scopes) {
+		return nil, newOauthError(http.StatusForbidden, "insufficient_scope", "缺少openid scope")
+	}
+	userId, _ := util.String2Int64(claims.Subject)
+	user, gfsErr := findActiveUser(c.UserContext(), userId)
+	if gfsErr != nil {
+		return nil, newOauthError(http.StatusUnauthorized, "invalid_token", gfsErr.GetMsg())
+	}
+
+	info := map[string]any{"sub": claims.Subject}
+	if util.In("profile", scopes) {
+		info["name"] = user.Name
+		info["nickname"] = user.Nickname
+		info["picture"] = user.Avatar
+	}
+	if util.In("email", scopes) && user.Email != nil {
+		info["email"] = *user.Email
+		info["email_verified"] = !user.Oauth // 邮箱注册需验证码
+	}
+	return info, nil
+}
+
+// Revoke 撤销端点 RFC 7009 无论令牌是否有效均返回成功
+func (svc *idpService) Revoke(c *fiber.Ctx, req models.RevokeRequest) *models.OauthError {
+	client, oerr := authenticateClient(c, req.ClientId, req.ClientSecret)
+	if oerr != nil {
+		return oerr
+	}
+	if req.TokenTypeHint != "access_token" && revokeRefreshToken(c.UserContext(), client.ClientID, req.Token) {
+		return nil
+	}
+	if revokeAccessToken(c, client.ClientID, req.Token) {
+		return nil
+	}
+	// 登录凭证不绑定客户端 仅允许机密客户端撤销
+	if !client.Public {
+		revokeSessionToken(c.UserContext(), req.Token)
+	}
+	return nil
+}
+
+// CreateClient 登记客户端 返回明文密钥 仅此一次可见
+func (svc *idpService) CreateClient(req models.CreateClientRequest) (record models.GfOauthClient, secret string, err common.GFError) {
+	reqErr := ca.ValidateServiceApi.Validate(req)
+	if reqErr != nil {
+		return record, "", common.NewServiceError("入参有误: " + reqErr[0].ErrMsg)
+	}
+	record = models.GfOauthClient{
+		ClientID:     util.GenerateSecureToken(16),
+		RedirectUris: req.RedirectUris,
+		Scopes:       req.Scopes,
+		Public:       req.Public,
+		Trusted:      req.Trusted,
+		Status:       "normal",
+	}
+	record.SetNewId()
+	record.SetName(req.Name)
+	record.CreateTime = cm.LocalTime(time.Now())
+	record.UpdateTime = record.CreateTime
+	if !req.Public {
+		secret = util.GenerateSecureToken(32)
+		record.ClientSecret = util.CreateSHA256(secret)
+	}
+	if err = dao.GetClientDao().Add(&record); err != nil {
+		return record, "", err
+	}
+	return record, secret, nil
+}
+
+// authenticateClient 客户端认证 支持 client_secret_basic client_secret_post 与公开客户端
+func authenticateClient(c *fiber.Ctx, clientId string, clientSecret string) (*models.GfOauthClient, *models.OauthError) {
+	if basicId, basicSecret, ok := parseBasicAuth(c.Get(fiber.HeaderAuthorization)); ok {
+		clientId, clientSecret = basicId, basicSecret
+	}
+	if clientId == "" {
+		return nil, newOauthError(http.StatusUnauthorized, "invalid_client", "缺少客户端认证")
+	}
+	client, err := dao.GetClientDao().FindOneByClientId(clientId)
+	if err != nil || client.Status != "normal" {
+		return nil, newOauthError(http.StatusUnauthorized, "invalid_client", "客户端认证失败")
+	}
+	if !client.Public {
+		hashed := util.CreateSHA256(clientSecret)
+		if clientSecret == "" || subtle.ConstantTimeCompare([]byte(hashed), []byte(client.ClientSecret)) != 1 {
+			return nil, newOauthError(http.StatusUnauthorized, "invalid_client", "客户端认证失败")
+		}
+	}
+	return &client, nil
+}
+
+// parseBasicAuth 解析 Basic 认证 客户端标识与密钥按 RFC 6749 2.3.1 做过 URL 编码
+func parseBasicAuth(header string) (string, string, bool) {
+	const prefix = "Basic "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+	id, err1 := url.QueryUnescape(id)
+	secret, err2 := url.QueryUnescape(secret)
+	if err1 != nil || err2 != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// issueTokens 签发访问令牌 按 scope 签发刷新令牌与 ID Token
+func issueTokens(c *fiber.Ctx, client *models.GfOauthClient, userId int64, scope string, nonce string, authTime int64) (*models.TokenResponse, *models.OauthError) {
+	user, gfsErr := findActiveUser(c.UserContext(), userId)
+	if gfsErr != nil {
+		return nil, newOauthError(http.StatusBadRequest, "invalid_grant", gfsErr.GetMsg())
+	}
+	now := time.Now()
+	accessTTL := ttlOrDefault(env.GetServerConfig().Idp.AccessTokenTTL, time.Hour)
+	iss := issuer()
+	sub := strconv.FormatInt(user.ID, 10)
+
+	accessToken, err := signClaims(models.AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    iss,
+			Subject:   sub,
+			Audience:  jwt.ClaimStrings{client.ClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ID:        util.GenerateSecureToken(16),
+		},
+		ClientId: client.ClientID,
+		Scope:    scope,
+	})
+	if err != nil {
+		log.ErrorCtx(c.UserContext(), err)
+		return nil, newOauthError(http.StatusInternalServerError, "server_error", "签发令牌失败")
+	}
+	resp := &models.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(accessTTL.Seconds()),
+		Scope:       scope,
+	}
+
+	scopes := strings.Fields(scope)
+	if util.In("offline_access", scopes) {
+		refreshToken := util.GenerateSecureToken(32)
+		value, _ := sonic.MarshalString(models.RefreshToken{
+			ClientId: client.ClientID,
+			UserId:   user.ID,
+			Scope:    scope,
+			AuthTime: authTime,
+		})
+		refreshTTL := ttlOrDefault(env.GetServerConfig().Idp.RefreshTokenTTL, 30*24*time.Hour)
+		if cs.SetExpireCtx(c.UserContext(), refreshPrefix+util.CreateSHA256(refreshToken), value, refreshTTL) == nil {
+			resp.RefreshToken = refreshToken
+		}
+	}
+
+	if util.In("openid", scopes) {
+		idClaims := models.IdTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    iss,
+				Subject:   sub,
+				Audience:  jwt.ClaimStrings{client.ClientID},
+				ExpiresAt: jwt.NewNumericDate(now.Add(accessTTL)),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+			AuthTime: authTime,
+			Nonce:    nonce,
+		}
+		if util.In("profile", scopes) {
+			idClaims.Name = user.Name
+			idClaims.Nickname = user.Nickname
+			idClaims.Picture = user.Avatar
+		}
+		if util.In("email", scopes) && user.Email != nil {
+			verified := !user.Oauth
+			idClaims.Email = *user.Email
+			idClaims.EmailVerified = &verified
+		}
+		idToken, err := signClaims(idClaims)
+		if err != nil {
+			log.ErrorCtx(c.UserContext(), err)
+			return nil, newOauthError(http.StatusInternalServerError, "server_error", "签发令牌失败")
+		}
+		resp.IdToken = idToken
+	}
+	return resp, nil
+}
+
+// 撤销刷新令牌 仅限签发给该客户端的令牌
+func revokeRefreshToken(ctx context.Context, clientId string, token string) bool {
+	key := refreshPrefix + util.CreateSHA256(token)
+	value, gfsErr := cs.GetStringCtx(ctx, key)
+	if gfsErr != nil || value == "" {
+		return false
+	}
+	var refresh models.RefreshToken
+	if err := sonic.UnmarshalString(value, &refresh); err != nil || refresh.ClientId != clientId {
+		return false
+	}
+	_ = cs.DelCtx(ctx, key)
+	return true
+}
+
+// RevokeUserTokens 撤销签发给该用户的全部刷新令牌 访问令牌有效期短 到期后自然失效
+func (svc *idpService) RevokeUserTokens(userId int64) (int, common.GFError) {
+	keys, gfsErr := cs.FindByPrefix(refreshPrefix)
+	if gfsErr != nil {
+		return 0, gfsErr
+	}
+	var owned []string
+	for _, key := range keys {
+		value, err := cs.GetString(key)
+		if err != nil || value == "" {
+			continue
+		}
+		var refresh models.RefreshToken
+		if sonic.UnmarshalString(value, &refresh) == nil && refresh.UserId == userId {
+			owned = append(owned, key)
+		}
+	}
+	if len(owned) == 0 {
+		return 0, nil
+	}
+	if gfsErr = cs.Del(owned...); gfsErr != nil {
+		return 0, gfsErr
+	}
+	return len(owned), nil
+}
+
+// 撤销访问令牌 记录 jti 至令牌过期
+func revokeAccessToken(c *fiber.Ctx, clientId string, token string) bool {
+	claims, err := parseAccessToken(c, token)
+	if err != nil || claims.ClientId != clientId || claims.ExpiresAt == nil {
+		return false
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return false
+	}
+	return cs.SetExpireCtx(c.UserContext(), revokedPrefix+claims.ID, "1", ttl) == nil
+}
+
+// 访问令牌是否已撤销
+func isRevoked(ctx context.Context, jti string) bool {
+	value, gfsErr := cs.GetStringCtx(ctx, revokedPrefix+jti)
+	return gfsErr != nil || value != ""
+}
+
+// PKCE S256 校验
+func verifyPKCE(challenge string, verifier string) bool {
+	if challenge == "" || verifier == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// 查询未封禁的用户
+func findActiveUser(ctx context.Context, userId int64) (*um.GfUser, common.GFError) {
+	var record um.GfUser
+	if err := ud.GetUserDao().WithContext(ctx).GetById(userId, &record); err != nil {
+		return nil, common.NewServiceError("用户不存在")
+	}
+	if record.Status == "banned" {
+		return nil, common.NewServiceError("该用户已被封禁")
+	}
+	return &record, nil
+}
+
+// 在地址上追加查询参数 空值忽略
+func withQuery(rawUrl string, params map[string]string) string {
+	target, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	values := target.Query()
+	for k, v := range params {
+		if v != "" {
+			values.Set(k, v)
+		}
+	}
+	target.RawQuery = values.Encode()
+	return target.String()
+}
+
+// 从地址中去掉指定的查询参数
+func withoutQuery(rawUrl string, keys ...string) string {
+	target, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	values := target.Query()
+	for _, k := range keys {
+		values.Del(k)
+	}
+	target.RawQuery = values.Encode()
+	return target.String()
+}
@@ -0,0 +1,110 @@
+package service
+
+/*
+ * @Desc: IdP 签名密钥与令牌签发
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/apps/idp/models"
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	signingKey     *rsa.PrivateKey
+	signingKeyId   string
+	signingKeyErr  error
+	signingKeyOnce sync.Once
+)
+
+// 读取签名私钥 仅加载一次
+func loadSigningKey() (*rsa.PrivateKey, string, error) {
+	signingKeyOnce.Do(func() {
+		path := env.GetServerConfig().Idp.SigningKey
+		if path == "" {
+			signingKeyErr = errors.New("IdP未配置签名密钥")
+			return
+		}
+		signingKey, signingKeyErr = util.LoadRSAPrivateKey(path)
+		if signingKeyErr != nil {
+			return
+		}
+		signingKeyId = env.GetServerConfig().Idp.KeyId
+		if signingKeyId == "" {
+			// 未配置 kid 时取模数摘要前 16 位
+			signingKeyId = util.CreateSHA256(signingKey.N.String())[:16]
+		}
+	})
+	return signingKey, signingKeyId, signingKeyErr
+}
+
+// 签发者 只取配置 不使用请求中的 Host 避免签发任意 iss
+func issuer() string {
+	return strings.TrimSuffix(env.GetServerConfig().Idp.Issuer, "/")
+}
+
+// RS256 签名
+func signClaims(claims jwt.Claims) (string, error) {
+	key, kid, err := loadSigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+// 校验访问令牌签名与签发者
+func parseAccessToken(c *fiber.Ctx, tokenStr string, opts ...jwt.ParserOption) (*models.AccessClaims, error) {
+	key, _, err := loadSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, jwt.WithIssuer(issuer()), jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
+	claims := &models.AccessClaims{}
+	_, err = jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Jwks 公钥集合
+func (svc *idpService) Jwks() (map[string]any, error) {
+	key, kid, err := loadSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": jwt.SigningMethodRS256.Alg(),
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}, nil
+}
+
+// 有效期配置 未配置时取默认值
+func ttlOrDefault(seconds int, def time.Duration) time.Duration {
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -2,10 +2,11 @@ package controller
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/GoFurry/gofurry-user/apps/oauth/service"
 	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/metrics"
+	"github.com/GoFurry/gofurry-user/common/util"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -17,6 +18,40 @@ func init() {
 	OauthApi = &oauthApi{}
 }
 
+// @Summary Github 三方登录跳转
+// @Schemes
+// @Description 跳转 Github 授权页, 登录完成后回到 redirect_to
+// @Tags Oauth
+// @Accept json
+// @Produce json
+// @Param redirect_to query string false "登录后跳转地址, 须在白名单内"
+// @Success 302
+// @Router /oauth/login/github [Get]
+func (api *oauthApi) GithubLogin(c *fiber.Ctx) error {
+	authorizeUrl, err := service.GetOauthService().AuthorizeUrl(c.UserContext(), "github", c.Query("redirect_to"))
+	if err != nil {
+		return common.NewResponse(c).Error(err.GetMsg())
+	}
+	return c.Redirect(authorizeUrl, http.StatusFound)
+}
+
+// @Summary Gitee 三方登录跳转
+// @Schemes
+// @Description 跳转 Gitee 授权页, 登录完成后回到 redirect_to
+// @Tags Oauth
+// @Accept json
+// @Produce json
+// @Param redirect_to query string false "登录后跳转地址, 须在白名单内"
+// @Success 302
+// @Router /oauth/login/gitee [Get]
+func (api *oauthApi) GiteeLogin(c *fiber.Ctx) error {
+	authorizeUrl, err := service.GetOauthService().AuthorizeUrl(c.UserContext(), "gitee", c.Query("redirect_to"))
+	if err != nil {
+		return common.NewResponse(c).Error(err.GetMsg())
+	}
+	return c.Redirect(authorizeUrl, http.StatusFound)
+}
+
 // @Summary Github 三方登录
 // @Schemes
 // @Description Github 三方登录
@@ -24,26 +59,23 @@ func init() {
 // @Accept json
 // @Produce json
 // @Param code query string true "code"
+// @Param state query string true "state"
 // @Success 200 {object} common.ResultData
 // @Router /oauth/callback/github [Get]
 func (api *oauthApi) GithubCallback(c *fiber.Ctx) error {
+	redirectTo, err := service.GetOauthService().ConsumeState(c.UserContext(), "github", c.Query("state"))
+	if err != nil {
+		metrics.AuthEvent("oauth_github", "invalid_state")
+		return common.NewResponse(c).Error(err.GetMsg())
+	}
 	code := c.Query("code")
 	token, err := service.GetOauthService().GithubLogin(c, code)
 	if err != nil {
 		return common.NewResponse(c).Error(err)
 	}
 
-	c.Cookie(&fiber.Cookie{
-		Name:     "Authorization",
-		Value:    token,
-		Expires:  time.Now().Add(2 * time.Hour), // 2小时过期
-		Path:     "/",                           // 全站有效
-		Domain:   "127.0.0.1",                   // 前端域名
-		Secure:   false,                         // 开发环境 false 生产环境true
-		HTTPOnly: true,
-		SameSite: "Lax",
-	})
-	return c.Redirect("https://127.0.0.1:8888/", http.StatusFound)
+	c.Cookie(util.NewAuthCookie(token))
+	return c.Redirect(redirectTo, http.StatusFound)
 }
 
 // @Summary Gitee 三方登录
@@ -53,24 +85,21 @@ func (api *oauthApi) GithubCallback(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param code query string true "code"
+// @Param state query string true "state"
 // @Success 200 {object} common.ResultData
 // @Router /oauth/callback/gitee [Get]
 func (api *oauthApi) GiteeCallback(c *fiber.Ctx) error {
+	redirectTo, err := service.GetOauthService().ConsumeState(c.UserContext(), "gitee", c.Query("state"))
+	if err != nil {
+		metrics.AuthEvent("oauth_gitee", "invalid_state")
+		return common.NewResponse(c).Error(err.GetMsg())
+	}
 	code := c.Query("code")
 	token, err := service.GetOauthService().GiteeLogin(c, code)
 	if err != nil {
 		return common.NewResponse(c).Error(err)
 	}
 
-	c.Cookie(&fiber.Cookie{
-		Name:     "Authorization",
-		Value:    token,
-		Expires:  time.Now().Add(2 * time.Hour), // 2小时过期
-		Path:     "/",                           // 全站有效
-		Domain:   "127.0.0.1",                   // 前端域名
-		Secure:   false,                         // 开发环境 false 生产环境true
-		HTTPOnly: true,
-		SameSite: "Lax",
-	})
-	return c.Redirect("https://127.0.0.1:8888/", http.StatusFound)
+	c.Cookie(util.NewAuthCookie(token))
+	return c.Redirect(redirectTo, http.StatusFound)
 }
@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"context"
 	"errors"
 
 	"github.com/GoFurry/gofurry-user/apps/oauth/models"
@@ -20,6 +21,11 @@ type oauthDao struct{ abstract.Dao }
 
 func GetOauthDao() *oauthDao { return newOauthDao }
 
+// WithContext 绑定请求上下文 语句纳入链路追踪
+func (dao *oauthDao) WithContext(ctx context.Context) *oauthDao {
+	return &oauthDao{Dao: dao.Dao.WithContext(ctx)}
+}
+
 func (dao oauthDao) FindOneByName(name string, provider string) (record models.GfUserOauth, err common.GFError) {
 	db := dao.Gm.Table(models.TableNameGfUserOauth).Where("open_id = ? AND provider = ?", name, provider).Take(&record)
 	if err := db.Error; err != nil {
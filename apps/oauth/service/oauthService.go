@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"math/rand"
 	"strconv"
 	"time"
@@ -13,12 +14,12 @@ import (
 	us "github.com/GoFurry/gofurry-user/apps/user/service"
 	"github.com/GoFurry/gofurry-user/common"
 	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/common/metrics"
 	cm "github.com/GoFurry/gofurry-user/common/models"
 	cs "github.com/GoFurry/gofurry-user/common/service"
 	"github.com/GoFurry/gofurry-user/common/util"
 	"github.com/GoFurry/gofurry-user/roof/env"
 	"github.com/gofiber/fiber/v2"
-	"google.golang.org/grpc/credentials"
 )
 
 type oauthService struct{}
@@ -27,7 +28,28 @@ var oauthSingleton = new(oauthService)
 
 func GetOauthService() *oauthService { return oauthSingleton }
 
-func (s oauthService) GithubLogin(c *fiber.Ctx, code string) (string, common.GFError) {
+// AuthorizeUrl 生成三方授权页地址 state 中携带登录后的跳转地址
+func (s oauthService) AuthorizeUrl(ctx context.Context, provider string, redirectTo string) (string, common.GFError) {
+	if !env.GetServerConfig().Feature.OauthEnabled(provider) {
+		return "", common.NewServiceError("未启用该登录方式")
+	}
+	state, err := s.CreateState(ctx, provider, redirectTo)
+	if err != nil {
+		return "", err
+	}
+	switch provider {
+	case "github":
+		return cs.GetGithubAuthorizeUrl(state), nil
+	case "gitee":
+		return cs.GetGiteeAuthorizeUrl(state), nil
+	}
+	return "", common.NewServiceError("不支持的三方平台")
+}
+
+func (s oauthService) GithubLogin(c *fiber.Ctx, code string) (token string, gfErr common.GFError) {
+	reason := metrics.REASON_OK
+	defer func() { metrics.AuthEvent("oauth_github", reason) }()
+
 	// 单体架构版本
 	//accessCode, gfsErr := cs.GetGithubToken(code)
 	//if gfsErr != nil || accessCode == "" {
@@ -40,14 +62,10 @@ func (s oauthService) GithubLogin(c *fiber.Ctx, code string) (string, common.GFE
 	//userOpenID := gjson.Get(userInfo, "login").String() //github用户名唯一且不可修改
 
 	// 微服务版本
-	// 连接gRPC服务
-	creds, err := credentials.NewClientTLSFromFile(env.GetServerConfig().Key.GrpcTls, "")
-	if err != nil {
-		return "", common.NewServiceError("加载TLS证书失败: " + err.Error())
-	}
-	// 连接池复用 gRPC 连接
-	conn, err := util.GetGrpcClientConn("github-oauth-service", &creds)
+	// 连接池复用 gRPC 连接 证书按 grpc_clients 配置加载
+	conn, err := util.GetGrpcClientConn("github-oauth-service")
 	if err != nil {
+		reason = "grpc_unavailable"
 		return "", common.NewServiceError("获取 gRPC 连接失败: " + err.Error())
 	}
 
@@ -55,48 +73,51 @@ func (s oauthService) GithubLogin(c *fiber.Ctx, code string) (string, common.GFE
 	client := githuboauth.NewGithubOAuthServiceClient(conn)
 
 	// gRPC 获取令牌
-	tokenResp, err := client.GetAccessToken(c.Context(), &githuboauth.GetAccessTokenRequest{
+	tokenResp, err := client.GetAccessToken(c.UserContext(), &githuboauth.GetAccessTokenRequest{
 		Code: code,
 	})
 	if err != nil {
+		reason = "token_exchange"
 		return "", common.NewServiceError("获取accessToken失败: " + err.Error())
 	}
 	if tokenResp.Error != "" {
+		reason = "token_exchange"
 		return "", common.NewServiceError("获取accessToken失败: " + tokenResp.Error)
 	}
 	accessToken := tokenResp.AccessToken
 
 	// gRPC 获取用户信息
-	userResp, err := client.GetUserInfo(c.Context(), &githuboauth.GetUserInfoRequest{
+	userResp, err := client.GetUserInfo(c.UserContext(), &githuboauth.GetUserInfoRequest{
 		AccessToken: accessToken,
 	})
 	if err != nil {
+		reason = "user_info"
 		return "", common.NewServiceError("获取userOpenID失败: " + err.Error())
 	}
 	if userResp.Error != "" {
+		reason = "user_info"
 		return "", common.NewServiceError("获取userOpenID失败: " + userResp.Error)
 	}
 	userInfo := userResp.UserInfo
 	userOpenID := userInfo.Login // GitHub用户名 唯一标识
 
-	return oauthLogin(c, userOpenID, "github")
+	token, gfErr = oauthLogin(c, userOpenID, "github")
+	if gfErr != nil {
+		reason = "login_failed"
+	}
+	return token, gfErr
 }
 
 func (s oauthService) GiteeLogin(c *fiber.Ctx, code string) (string, common.GFError) {
-	// 连接gRPC服务
-	//creds, err := credentials.NewClientTLSFromFile(env.GetServerConfig().Key.GrpcTls, "")
-	//if err != nil {
-	//	return "", common.NewServiceError("加载TLS证书失败: " + err.Error())
-	//}
 	// 连接池复用 gRPC 连接
-	conn, err := util.GetGrpcClientConn("github-oauth-service", nil)
+	conn, err := util.GetGrpcClientConn("github-oauth-service")
 	if err != nil {
 		return "", common.NewServiceError("获取 gRPC 连接失败: " + err.Error())
 	}
 
 	// 创建客户端
 	client := githuboauth.NewGithubOAuthServiceClient(conn)
-	client = client
+	_ = client
 	// TODO:
 
 	return "", nil
@@ -104,13 +125,21 @@ func (s oauthService) GiteeLogin(c *fiber.Ctx, code string) (string, common.GFEr
 
 // oauthLogin 注册/登录逻辑
 func oauthLogin(c *fiber.Ctx, userOpenID string, provider string) (tokenStr string, err common.GFError) {
+	ctx := c.UserContext()
+	// 授权期间可能已停用该登录方式
+	if !env.GetServerConfig().Feature.OauthEnabled(provider) {
+		return "", common.NewServiceError("未启用该登录方式")
+	}
 	//查找是否已注册
-	oauthRecord, err := dao.GetOauthDao().FindOneByName(userOpenID, provider)
+	oauthRecord, err := dao.GetOauthDao().WithContext(ctx).FindOneByName(userOpenID, provider)
 	if err != nil && err.GetMsg() != common.RETURN_RECORD_NOT_FOUND {
 		return
 	}
 	//没找到就注册账户
 	if err != nil && err.GetMsg() == common.RETURN_RECORD_NOT_FOUND {
+		if !env.GetServerConfig().Feature.RegisterEnabled() {
+			return "", common.NewServiceError("暂未开放注册")
+		}
 		newUserRecord := &um.GfUser{
 			Nickname: userOpenID,
 			Email:    nil,
@@ -135,25 +164,25 @@ func oauthLogin(c *fiber.Ctx, userOpenID string, provider string) (tokenStr stri
 		}
 		newOauthRecord.SetNewId()
 		// 记录入库
-		err = ud.GetUserDao().Add(newUserRecord)
+		err = ud.GetUserDao().WithContext(ctx).Add(newUserRecord)
 		if err != nil {
 			return
 		}
 
-		err = dao.GetOauthDao().Add(newOauthRecord)
+		err = dao.GetOauthDao().WithContext(ctx).Add(newOauthRecord)
 		if err != nil {
 			return
 		}
 	}
 
 	//登录账户
-	oauthRecord, err = dao.GetOauthDao().FindOneByName(userOpenID, "github")
+	oauthRecord, err = dao.GetOauthDao().WithContext(ctx).FindOneByName(userOpenID, "github")
 	if err != nil {
 		return
 	}
 
 	var record *um.GfUser
-	err = ud.GetUserDao().GetById(oauthRecord.UserID, &record)
+	err = ud.GetUserDao().WithContext(ctx).GetById(oauthRecord.UserID, &record)
 	if err != nil {
 		return
 	}
@@ -161,10 +190,10 @@ func oauthLogin(c *fiber.Ctx, userOpenID string, provider string) (tokenStr stri
 	// 生成 token 存 redis
 	tokenStr, tokenErr := util.NewToken(strconv.FormatInt(record.ID, 10), record.Name)
 	if tokenErr != nil {
-		log.Error(tokenErr)
+		log.ErrorCtx(ctx, tokenErr)
 		return "", common.NewServiceError("创建Token错误.")
 	}
-	cs.SetExpire("jwt:"+tokenStr, tokenStr, common.JWT_RELET_NUM*time.Hour) //存 token
+	cs.SetExpireCtx(ctx, "jwt:"+tokenStr, tokenStr, common.JWT_RELET_NUM*time.Hour) //存 token
 
 	currentUser := um.CurrentUser{
 		ID:   record.ID,
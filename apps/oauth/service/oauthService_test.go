@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoFurry/gofurry-user/apps/oauth/models"
+	us "github.com/GoFurry/gofurry-user/apps/user/service"
+	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/roof/db"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// setup 使用 conf/server.yaml 中的 sqlite 内存库 redis 由 miniredis 替代
+func setup(t *testing.T) {
+	t.Helper()
+	if err := db.Orm.MigrateOnStart(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	env.GetServerConfig().Redis.RedisAddr = miniredis.RunT(t).Addr()
+	cs.InitRedisOnStart()
+}
+
+// state 只能使用一次 跳转地址须在白名单内
+func TestState(t *testing.T) {
+	setup(t)
+	ctx := context.Background()
+	svc := GetOauthService()
+
+	if _, err := svc.CreateState(ctx, "github", "https://evil.example.com"); err == nil {
+		t.Fatal("redirect outside allowlist accepted")
+	}
+	if _, err := svc.ConsumeState(ctx, "github", ""); err == nil {
+		t.Fatal("empty state accepted")
+	}
+	state, err := svc.CreateState(ctx, "github", "/home")
+	if err != nil {
+		t.Fatalf("create state: %s", err.GetMsg())
+	}
+	target, err := svc.ConsumeState(ctx, "github", state)
+	if err != nil {
+		t.Fatalf("consume state: %s", err.GetMsg())
+	}
+	if target != "https://gofurry.cn/home" {
+		t.Fatalf("redirect = %q", target)
+	}
+	if _, err = svc.ConsumeState(ctx, "github", state); err == nil {
+		t.Fatal("state reused")
+	}
+}
+
+// 首次三方登录自动注册 再次登录使用同一账户
+func TestOauthLogin(t *testing.T) {
+	setup(t)
+	ctx := context.Background()
+	app := fiber.New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	login := func() string {
+		token, err := oauthLogin(c, "10001", "github")
+		if err != nil {
+			t.Fatalf("oauth login: %s", err.GetMsg())
+		}
+		claims, err := us.GetUserService().ValidateSessionToken(ctx, token)
+		if err != nil {
+			t.Fatalf("validate session: %s", err.GetMsg())
+		}
+		return claims.UserId
+	}
+	first, second := login(), login()
+	if first != second {
+		t.Fatalf("second login created user %s, want %s", second, first)
+	}
+	var count int64
+	db.Orm.DB().Table(models.TableNameGfUserOauth).Where("open_id = ?", "10001").Count(&count)
+	if count != 1 {
+		t.Fatalf("%d oauth bindings, want 1", count)
+	}
+}
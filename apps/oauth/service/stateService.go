@@ -0,0 +1,137 @@
+package service
+
+/*
+ * @Desc: 三方登录 state 与跳转白名单
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/log"
+	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/bytedance/sonic"
+)
+
+const oauthStatePrefix = "oauth:state:"
+
+// oauthState 随 state 一起存入 redis 的登录上下文
+type oauthState struct {
+	Provider   string `json:"provider"`
+	RedirectTo string `json:"redirectTo"`
+}
+
+// CreateState 生成 state 并记录登录完成后的跳转地址
+func (s oauthService) CreateState(ctx context.Context, provider string, redirectTo string) (string, common.GFError) {
+	if redirectTo != "" {
+		if _, ok := ResolveRedirect(redirectTo); !ok {
+			return "", common.NewServiceError("跳转地址不在白名单内")
+		}
+	}
+	state := util.GenerateSecureToken(24)
+	value, err := sonic.MarshalString(oauthState{Provider: provider, RedirectTo: redirectTo})
+	if err != nil {
+		log.ErrorCtx(ctx, err)
+		return "", common.NewServiceError("生成state失败")
+	}
+	if gfsErr := cs.SetExpireCtx(ctx, oauthStatePrefix+state, value, common.OAUTH_STATE_TTL*time.Minute); gfsErr != nil {
+		return "", gfsErr
+	}
+	return state, nil
+}
+
+// ConsumeState 校验并销毁 state 返回最终跳转地址
+// 登录入口总会签发 state 未携带或已使用的 state 一律拒绝 防止登录 CSRF
+func (s oauthService) ConsumeState(ctx context.Context, provider string, state string) (string, common.GFError) {
+	if state == "" {
+		return "", common.NewServiceError("缺少state")
+	}
+	value, gfsErr := cs.GetStringCtx(ctx, oauthStatePrefix+state)
+	if gfsErr != nil {
+		return "", gfsErr
+	}
+	if value == "" {
+		return "", common.NewServiceError("state无效或已过期")
+	}
+	_ = cs.DelCtx(ctx, oauthStatePrefix+state)
+
+	var record oauthState
+	if err := sonic.UnmarshalString(value, &record); err != nil || record.Provider != provider {
+		return "", common.NewServiceError("state无效或已过期")
+	}
+	if record.RedirectTo == "" {
+		return defaultRedirect(), nil
+	}
+	// 白名单可能已变更 回调时再次校验
+	target, ok := ResolveRedirect(record.RedirectTo)
+	if !ok {
+		return defaultRedirect(), nil
+	}
+	return target, nil
+}
+
+// ResolveRedirect 校验跳转地址是否在白名单内 相对路径基于默认地址补全
+func ResolveRedirect(redirectTo string) (string, bool) {
+	redirectTo = strings.TrimSpace(redirectTo)
+	if redirectTo == "" {
+		return "", false
+	}
+	// 相对路径 拒绝 //host 与 /\host 形式的协议相对地址
+	if strings.HasPrefix(redirectTo, "/") {
+		if strings.HasPrefix(redirectTo, "//") || strings.HasPrefix(redirectTo, "/\\") {
+			return "", false
+		}
+		base, err := url.Parse(defaultRedirect())
+		if err != nil {
+			return "", false
+		}
+		ref, err := url.Parse(redirectTo)
+		if err != nil {
+			return "", false
+		}
+		return base.ResolveReference(ref).String(), true
+	}
+
+	target, err := url.Parse(redirectTo)
+	if err != nil || target.Host == "" || (target.Scheme != "https" && target.Scheme != "http") {
+		return "", false
+	}
+	for _, allowed := range env.GetServerConfig().Auth.Redirect.Allowlist {
+		if matchOrigin(allowed, target) {
+			return target.String(), true
+		}
+	}
+	return "", false
+}
+
+// matchOrigin 比较协议与主机 支持 https://*.gofurry.cn 形式的子域通配
+func matchOrigin(allowed string, target *url.URL) bool {
+	origin, err := url.Parse(strings.TrimSpace(allowed))
+	if err != nil || origin.Host == "" {
+		return false
+	}
+	if !strings.EqualFold(origin.Scheme, target.Scheme) {
+		return false
+	}
+	host := strings.ToLower(target.Host)
+	pattern := strings.ToLower(origin.Host)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
+
+// 登录后默认跳转地址
+func defaultRedirect() string {
+	if def := env.GetServerConfig().Auth.Redirect.DefaultUrl; def != "" {
+		return def
+	}
+	return "/"
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/GoFurry/gofurry-user/apps/user/models"
 	"github.com/GoFurry/gofurry-user/apps/user/service"
 	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/util"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -35,6 +36,8 @@ func (api *userApi) Login(c *fiber.Ctx) error {
 	if err != nil {
 		return common.NewResponse(c).Error(err.GetMsg())
 	}
+	// 同时写入 Cookie 供单点登录使用
+	c.Cookie(util.NewAuthCookie(token))
 	var data = map[string]interface{}{
 		"token": token,
 	}
@@ -55,9 +58,73 @@ func (api *userApi) Register(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return common.NewResponse(c).Error("参数错误: " + err.Error())
 	}
-	err := service.GetUserService().Register(req)
+	err := service.GetUserService().Register(c.UserContext(), req)
 	if err != nil {
 		return common.NewResponse(c).Error(err.GetMsg())
 	}
 	return common.NewResponse(c).Success()
 }
+
+// @Summary 创建个人访问令牌
+// @Schemes
+// @Description 创建个人访问令牌, 明文令牌仅返回一次
+// @Tags System-user
+// @Accept json
+// @Produce json
+// @Param body body models.CreateTokenRequest true "请求body"
+// @Success 200 {object} common.ResultData
+// @Router /api/user/token/create [Post]
+func (api *userApi) CreateToken(c *fiber.Ctx) error {
+	// 个人访问令牌不能再签发令牌
+	if c.Locals(common.COMMON_AUTH_SCOPES) != nil {
+		return common.NewResponse(c).ErrorWithCode("请使用登录凭证创建令牌", fiber.StatusForbidden)
+	}
+	var req models.CreateTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return common.NewResponse(c).Error("参数错误: " + err.Error())
+	}
+	currentUser := c.Locals(common.COMMON_AUTH_CURRENT).(models.CurrentUser)
+	resp, err := service.GetUserService().CreatePersonalToken(c.UserContext(), currentUser.ID, req)
+	if err != nil {
+		return common.NewResponse(c).Error(err.GetMsg())
+	}
+	return common.NewResponse(c).SuccessWithData(resp)
+}
+
+// @Summary 个人访问令牌列表
+// @Schemes
+// @Description 个人访问令牌列表
+// @Tags System-user
+// @Accept json
+// @Produce json
+// @Success 200 {object} common.ResultData
+// @Router /api/user/token/list [Get]
+func (api *userApi) ListToken(c *fiber.Ctx) error {
+	currentUser := c.Locals(common.COMMON_AUTH_CURRENT).(models.CurrentUser)
+	records, err := service.GetUserService().ListPersonalTokens(currentUser.ID)
+	if err != nil {
+		return common.NewResponse(c).Error(err.GetMsg())
+	}
+	return common.NewResponse(c).SuccessWithData(records)
+}
+
+// @Summary 撤销个人访问令牌
+// @Schemes
+// @Description 撤销个人访问令牌
+// @Tags System-user
+// @Accept json
+// @Produce json
+// @Param id query string true "令牌id"
+// @Success 200 {object} common.ResultData
+// @Router /api/user/token/delete [Post]
+func (api *userApi) DeleteToken(c *fiber.Ctx) error {
+	id, parseErr := util.String2Int64(c.Query("id"))
+	if parseErr != nil {
+		return common.NewResponse(c).Error("参数错误: " + parseErr.Error())
+	}
+	currentUser := c.Locals(common.COMMON_AUTH_CURRENT).(models.CurrentUser)
+	if err := service.GetUserService().RevokePersonalToken(currentUser.ID, id); err != nil {
+		return common.NewResponse(c).Error(err.GetMsg())
+	}
+	return common.NewResponse(c).Success()
+}
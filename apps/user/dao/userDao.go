@@ -1,7 +1,9 @@
 package dao
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/GoFurry/gofurry-user/apps/user/models"
 	"github.com/GoFurry/gofurry-user/common"
@@ -20,6 +22,11 @@ type userDao struct{ abstract.Dao }
 
 func GetUserDao() *userDao { return newUserDao }
 
+// WithContext 绑定请求上下文 语句纳入链路追踪
+func (dao *userDao) WithContext(ctx context.Context) *userDao {
+	return &userDao{Dao: dao.Dao.WithContext(ctx)}
+}
+
 func (dao *userDao) FindOneByName(name string) (record models.GfUser, err common.GFError) {
 	db := dao.Gm.Table(models.TableNameGfUser).Where("name = ?", name).Take(&record)
 	if err := db.Error; err != nil {
@@ -43,3 +50,28 @@ func (dao *userDao) FindOneByEmail(email string) (record models.GfUser, err comm
 	}
 	return
 }
+
+// FindByIds 批量查询用户 查询走只读副本
+func (dao *userDao) FindByIds(ids []int64) (records []models.GfUser, err common.GFError) {
+	db := dao.Replica().Table(models.TableNameGfUser).Where("id in ?", ids).Find(&records)
+	if err := db.Error; err != nil {
+		return nil, common.NewDaoError(err.Error())
+	}
+	return
+}
+
+func (dao *userDao) UpdateStatus(id int64, status string) (int64, common.GFError) {
+	db := dao.Gm.Table(models.TableNameGfUser).Where("id = ?", id).Updates(map[string]any{"status": status, "update_time": time.Now()})
+	if err := db.Error; err != nil {
+		return 0, common.NewDaoError(err.Error())
+	}
+	return db.RowsAffected, nil
+}
+
+func (dao *userDao) UpdatePassword(id int64, password string) (int64, common.GFError) {
+	db := dao.Gm.Table(models.TableNameGfUser).Where("id = ?", id).Updates(map[string]any{"password": password, "update_time": time.Now()})
+	if err := db.Error; err != nil {
+		return 0, common.NewDaoError(err.Error())
+	}
+	return db.RowsAffected, nil
+}
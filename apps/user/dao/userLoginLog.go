@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"context"
 	"github.com/GoFurry/gofurry-user/apps/user/models"
 	"github.com/GoFurry/gofurry-user/common/abstract"
 )
@@ -15,3 +16,8 @@ func init() {
 type userLogDao struct{ abstract.Dao }
 
 func GetUserLogDao() *userLogDao { return newUserLogDao }
+
+// WithContext 绑定请求上下文 语句纳入链路追踪
+func (dao *userLogDao) WithContext(ctx context.Context) *userLogDao {
+	return &userLogDao{Dao: dao.Dao.WithContext(ctx)}
+}
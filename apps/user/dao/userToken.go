@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/apps/user/models"
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/abstract"
+	"gorm.io/gorm"
+)
+
+var newUserTokenDao = new(userTokenDao)
+
+func init() {
+	newUserTokenDao.Init()
+	newUserTokenDao.Mode = models.GfUserToken{}
+}
+
+type userTokenDao struct{ abstract.Dao }
+
+func GetUserTokenDao() *userTokenDao { return newUserTokenDao }
+
+// WithContext 绑定请求上下文 语句纳入链路追踪
+func (dao *userTokenDao) WithContext(ctx context.Context) *userTokenDao {
+	return &userTokenDao{Dao: dao.Dao.WithContext(ctx)}
+}
+
+func (dao *userTokenDao) FindOneByHash(hash string) (record models.GfUserToken, err common.GFError) {
+	db := dao.Gm.Table(models.TableNameGfUserToken).Where("token_hash = ?", hash).Take(&record)
+	if err := db.Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return record, common.NewDaoError(common.RETURN_RECORD_NOT_FOUND)
+		} else {
+			return record, common.NewDaoError(err.Error())
+		}
+	}
+	return
+}
+
+func (dao *userTokenDao) FindByUserId(userId int64) (records []models.GfUserToken, err common.GFError) {
+	db := dao.Gm.Table(models.TableNameGfUserToken).Where("user_id = ? AND revoked = ?", userId, false).Order("create_time desc").Find(&records)
+	if err := db.Error; err != nil {
+		return nil, common.NewDaoError(err.Error())
+	}
+	return
+}
+
+func (dao *userTokenDao) CountByUserId(userId int64) (count int64, err common.GFError) {
+	db := dao.Gm.Table(models.TableNameGfUserToken).Where("user_id = ? AND revoked = ?", userId, false).Count(&count)
+	if err := db.Error; err != nil {
+		return 0, common.NewDaoError(err.Error())
+	}
+	return
+}
+
+func (dao *userTokenDao) Revoke(id int64, userId int64) (int64, common.GFError) {
+	db := dao.Gm.Table(models.TableNameGfUserToken).Where("id = ? AND user_id = ?", id, userId).Update("revoked", true)
+	if err := db.Error; err != nil {
+		return 0, common.NewDaoError(err.Error())
+	}
+	return db.RowsAffected, nil
+}
+
+func (dao *userTokenDao) TouchLastUsed(id int64, usedAt time.Time) common.GFError {
+	db := dao.Gm.Table(models.TableNameGfUserToken).Where("id = ?", id).Update("last_used_time", usedAt)
+	if err := db.Error; err != nil {
+		return common.NewDaoError(err.Error())
+	}
+	return nil
+}
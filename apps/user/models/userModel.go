@@ -10,16 +10,16 @@ const TableNameGfUser = "gf_user"
 // GfUser mapped from table <gf_user>
 type GfUser struct {
 	abstract.DefaultModel
-	Nickname   string       `gorm:"column:nickname;type:character varying(60);not null;comment:用户名" json:"nickname"`                  // 用户名
-	Email      *string      `gorm:"column:email;type:character varying(100);comment:用户邮箱" json:"email"`                               // 用户邮箱
-	Oauth      bool         `gorm:"column:oauth;type:boolean;not null;comment:是否三方登录" json:"oauth"`                                   // 是否三方登录
-	Password   string       `gorm:"column:password;type:character varying(255);not null;comment:用户密码" json:"password"`                // 用户密码
-	Role       string       `gorm:"column:role;type:character varying(50);comment:用户身份" json:"role"`                                  // 用户身份
-	Info       *string      `gorm:"column:info;type:character varying(255);comment:用户信息" json:"info"`                                 // 用户信息
-	CreateTime cm.LocalTime `gorm:"column:create_time;type:int;type:unsigned;not null;autoCreateTime;comment:创建时间" json:"createTime"` // 创建时间
-	UpdateTime cm.LocalTime `gorm:"column:update_time;type:int;type:unsigned;not null;autoUpdateTime;comment:更新时间" json:"updateTime"` // 更新时间
-	Status     string       `gorm:"column:status;type:character varying(20);not null;comment:用户状态" json:"status"`                     // 用户状态
-	Avatar     string       `gorm:"column:avatar;type:character varying(255);not null;comment:用户头像" json:"avatar"`                    // 用户头像
+	Nickname   string       `gorm:"column:nickname;type:character varying(60);not null;comment:用户名" json:"nickname"`                 // 用户名
+	Email      *string      `gorm:"column:email;type:character varying(100);uniqueIndex:uk_gf_user_email;comment:用户邮箱" json:"email"` // 用户邮箱
+	Oauth      bool         `gorm:"column:oauth;type:boolean;not null;comment:是否三方登录" json:"oauth"`                                  // 是否三方登录
+	Password   string       `gorm:"column:password;type:character varying(255);not null;comment:用户密码" json:"password"`               // 用户密码
+	Role       string       `gorm:"column:role;type:character varying(50);comment:用户身份" json:"role"`                                 // 用户身份
+	Info       *string      `gorm:"column:info;type:character varying(255);comment:用户信息" json:"info"`                                // 用户信息
+	CreateTime cm.LocalTime `gorm:"column:create_time;type:timestamp;not null;autoCreateTime;comment:创建时间" json:"createTime"`        // 创建时间
+	UpdateTime cm.LocalTime `gorm:"column:update_time;type:timestamp;not null;autoUpdateTime;comment:更新时间" json:"updateTime"`        // 更新时间
+	Status     string       `gorm:"column:status;type:character varying(20);not null;comment:用户状态" json:"status"`                    // 用户状态
+	Avatar     string       `gorm:"column:avatar;type:character varying(255);not null;comment:用户头像" json:"avatar"`                   // 用户头像
 }
 
 // TableName GfUser's table name
@@ -32,11 +32,11 @@ const TableNameGfLoginLog = "gf_login_log"
 // GfLoginLog mapped from table <gf_login_log>
 type GfLoginLog struct {
 	abstract.IdModel
-	UserID     int64        `gorm:"column:user_id;type:bigint;not null;comment:用户表id" json:"userId,string"`                             // 用户表id
-	Agent      string       `gorm:"column:agent;type:character varying(255);not null;comment:浏览器信息" json:"agent"`                       // 浏览器信息
-	IP         string       `gorm:"column:ip;type:character varying(255);not null;comment:登录 ip" json:"ip"`                             // 登录 ip
-	CreateTime cm.LocalTime `gorm:"column:create_time;type:int;type:unsigned;not null;autoCreateTime;comment:日志创建时间" json:"createTime"` // 日志创建时间
-	LoginType  string       `gorm:"column:login_type;type:character varying(20);not null;comment:记录登录方式" json:"loginType"`              // 记录登录方式
+	UserID     int64        `gorm:"column:user_id;type:bigint;not null;index:idx_gf_login_log_user_id,priority:1;comment:用户表id" json:"userId,string"`                     // 用户表id
+	Agent      string       `gorm:"column:agent;type:character varying(255);not null;comment:浏览器信息" json:"agent"`                                                         // 浏览器信息
+	IP         string       `gorm:"column:ip;type:character varying(255);not null;comment:登录 ip" json:"ip"`                                                               // 登录 ip
+	CreateTime cm.LocalTime `gorm:"column:create_time;type:timestamp;not null;autoCreateTime;index:idx_gf_login_log_user_id,priority:2;comment:日志创建时间" json:"createTime"` // 日志创建时间
+	LoginType  string       `gorm:"column:login_type;type:character varying(20);not null;comment:记录登录方式" json:"loginType"`                                                // 记录登录方式
 }
 
 // TableName GfLoginLog's table name
@@ -61,3 +61,34 @@ type UserRegisterRequest struct {
 	Code     string `json:"code" validate:"required,len=6"`
 	Role     string `json:"role" validate:"required"`
 }
+
+const TableNameGfUserToken = "gf_user_token"
+
+// GfUserToken mapped from table <gf_user_token>
+type GfUserToken struct {
+	abstract.DefaultModel
+	UserID       int64         `gorm:"column:user_id;type:bigint;not null;comment:用户表id" json:"userId,string"`                                        // 用户表id
+	TokenHash    string        `gorm:"column:token_hash;type:character varying(64);not null;uniqueIndex:uk_gf_user_token_hash;comment:令牌摘要" json:"-"` // 令牌摘要
+	TokenPrefix  string        `gorm:"column:token_prefix;type:character varying(16);not null;comment:令牌前缀" json:"tokenPrefix"`                       // 令牌前缀 便于辨认
+	Scopes       string        `gorm:"column:scopes;type:character varying(255);not null;comment:权限范围 空格分隔" json:"scopes"`                            // 权限范围 空格分隔
+	ExpireTime   *cm.LocalTime `gorm:"column:expire_time;type:timestamp;comment:过期时间" json:"expireTime"`                                              // 过期时间 为空永不过期
+	LastUsedTime *cm.LocalTime `gorm:"column:last_used_time;type:timestamp;comment:最后使用时间" json:"lastUsedTime"`                                       // 最后使用时间
+	Revoked      bool          `gorm:"column:revoked;type:boolean;not null;comment:是否已撤销" json:"revoked"`                                             // 是否已撤销
+	CreateTime   cm.LocalTime  `gorm:"column:create_time;type:timestamp;not null;autoCreateTime;comment:创建时间" json:"createTime"`                      // 创建时间
+}
+
+// TableName GfUserToken's table name
+func (*GfUserToken) TableName() string {
+	return TableNameGfUserToken
+}
+
+type CreateTokenRequest struct {
+	Name       string `json:"name" validate:"required,max=60"`
+	Scopes     string `json:"scopes" validate:"required"`           // 权限范围 空格分隔 至少一个
+	ExpireDays int    `json:"expireDays" validate:"min=0,max=3650"` // 有效天数 0 为永不过期
+}
+
+type CreateTokenResponse struct {
+	Token  string      `json:"token"` // 明文令牌 仅此一次可见
+	Record GfUserToken `json:"record"`
+}
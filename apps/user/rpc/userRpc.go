@@ -0,0 +1,96 @@
+package rpc
+
+/*
+ * @Desc: 用户 gRPC 接口
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/apps/proto/userservice"
+	"github.com/GoFurry/gofurry-user/apps/user/models"
+	"github.com/GoFurry/gofurry-user/apps/user/service"
+	"github.com/GoFurry/gofurry-user/common/util"
+)
+
+type userRpc struct {
+	userservice.UnimplementedUserServiceServer
+}
+
+var UserRpc = &userRpc{}
+
+// ValidateToken 校验登录凭证
+func (r *userRpc) ValidateToken(ctx context.Context, req *userservice.ValidateTokenRequest) (*userservice.ValidateTokenResponse, error) {
+	claims, err := service.GetUserService().ValidateSessionToken(ctx, req.Token)
+	if err != nil {
+		return &userservice.ValidateTokenResponse{Valid: false, Error: err.GetMsg()}, nil
+	}
+	userId, parseErr := util.String2Int64(claims.UserId)
+	if parseErr != nil {
+		return &userservice.ValidateTokenResponse{Valid: false, Error: "登录信息有误"}, nil
+	}
+	resp := &userservice.ValidateTokenResponse{
+		Valid:    true,
+		UserId:   userId,
+		UserName: claims.UserName,
+	}
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	return resp, nil
+}
+
+// GetUser 查询用户
+func (r *userRpc) GetUser(ctx context.Context, req *userservice.GetUserRequest) (*userservice.GetUserResponse, error) {
+	record, err := service.GetUserService().GetUser(ctx, req.Id)
+	if err != nil {
+		return &userservice.GetUserResponse{Error: err.GetMsg()}, nil
+	}
+	return &userservice.GetUserResponse{User: toPbUser(record)}, nil
+}
+
+// BatchGetUsers 批量查询用户
+func (r *userRpc) BatchGetUsers(ctx context.Context, req *userservice.BatchGetUsersRequest) (*userservice.BatchGetUsersResponse, error) {
+	records, err := service.GetUserService().BatchGetUsers(req.Ids)
+	if err != nil {
+		return &userservice.BatchGetUsersResponse{Error: err.GetMsg()}, nil
+	}
+	users := make([]*userservice.User, 0, len(records))
+	for _, record := range records {
+		users = append(users, toPbUser(record))
+	}
+	return &userservice.BatchGetUsersResponse{Users: users}, nil
+}
+
+// CheckPermission 权限校验
+func (r *userRpc) CheckPermission(ctx context.Context, req *userservice.CheckPermissionRequest) (*userservice.CheckPermissionResponse, error) {
+	allowed, reason, err := service.GetUserService().CheckPermission(ctx, req.UserId, req.Permission)
+	if err != nil {
+		return &userservice.CheckPermissionResponse{Allowed: false, Error: err.GetMsg()}, nil
+	}
+	return &userservice.CheckPermissionResponse{Allowed: allowed, Reason: reason}, nil
+}
+
+// 转换为 protobuf 用户 不含密码
+func toPbUser(record models.GfUser) *userservice.User {
+	user := &userservice.User{
+		Id:         record.ID,
+		Name:       record.Name,
+		Nickname:   record.Nickname,
+		Role:       record.Role,
+		Status:     record.Status,
+		Avatar:     record.Avatar,
+		Oauth:      record.Oauth,
+		CreateTime: time.Time(record.CreateTime).Unix(),
+	}
+	if record.Email != nil {
+		user.Email = *record.Email
+	}
+	if record.Info != nil {
+		user.Info = *record.Info
+	}
+	return user
+}
@@ -0,0 +1,122 @@
+package service
+
+/*
+ * @Desc: 运维管理 供命令行使用 不经过 HTTP 鉴权
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/apps/user/dao"
+	"github.com/GoFurry/gofurry-user/apps/user/models"
+	"github.com/GoFurry/gofurry-user/common"
+	cm "github.com/GoFurry/gofurry-user/common/models"
+	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ROLE_ADMIN    = "admin"
+	STATUS_BANNED = "banned"
+)
+
+// FindUser 按 ID 邮箱或用户名查找用户
+func (svc *userService) FindUser(ref string) (record models.GfUser, err common.GFError) {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case util.IsNumber(ref):
+		id, _ := util.String2Int64(ref)
+		return svc.GetUser(context.Background(), id)
+	case strings.Contains(ref, "@"):
+		record, err = dao.GetUserDao().FindOneByEmail(ref)
+	default:
+		record, err = dao.GetUserDao().FindOneByName(ref)
+	}
+	if err != nil {
+		if err.GetMsg() == common.RETURN_RECORD_NOT_FOUND {
+			return record, common.NewServiceError("用户不存在")
+		}
+		return record, common.NewServiceError("查询用户失败")
+	}
+	return record, nil
+}
+
+// CreateUser 直接创建邮箱账户 不校验验证码
+func (svc *userService) CreateUser(email string, nickname string, password string, role string) (record models.GfUser, err common.GFError) {
+	if _, err = dao.GetUserDao().FindOneByEmail(email); err == nil {
+		return record, common.NewServiceError("邮箱已被注册")
+	}
+	if nickname == "" {
+		nickname = email[:strings.Index(email, "@")]
+	}
+	record = models.GfUser{
+		Password: util.CreateMD5(password + env.GetServerConfig().Auth.AuthSalt),
+		Nickname: nickname,
+		Role:     role,
+		Status:   "normal",
+		Avatar:   Avatars[0],
+	}
+	record.SetNewId()
+	record.SetName("UID:" + util.Int642String(record.ID))
+	record.CreateTime = cm.LocalTime(time.Now())
+	record.UpdateTime = record.CreateTime
+	record.Email = &email
+	defaultInfo := "暂无个人简介."
+	record.Info = &defaultInfo
+
+	if err = dao.GetUserDao().Add(&record); err != nil {
+		return record, common.NewServiceError("用户入库失败: " + err.GetMsg())
+	}
+	return record, nil
+}
+
+// ResetPassword 重置密码 已登录的会话随之失效
+func (svc *userService) ResetPassword(userId int64, password string) (revoked int, err common.GFError) {
+	password = util.CreateMD5(password + env.GetServerConfig().Auth.AuthSalt)
+	if _, err = dao.GetUserDao().UpdatePassword(userId, password); err != nil {
+		return 0, common.NewServiceError("重置密码失败: " + err.GetMsg())
+	}
+	return svc.RevokeSessions(userId)
+}
+
+// BanUser 封禁用户并撤销其登录会话
+func (svc *userService) BanUser(userId int64) (revoked int, err common.GFError) {
+	if _, err = dao.GetUserDao().UpdateStatus(userId, STATUS_BANNED); err != nil {
+		return 0, common.NewServiceError("封禁失败: " + err.GetMsg())
+	}
+	return svc.RevokeSessions(userId)
+}
+
+// RevokeSessions 删除该用户全部登录凭证 返回撤销数量
+// 凭证以 jwt:<token> 存于 redis 逐个解析载荷中的用户 不校验过期时间
+func (svc *userService) RevokeSessions(userId int64) (int, common.GFError) {
+	keys, err := cs.FindByPrefix("jwt:")
+	if err != nil {
+		return 0, err
+	}
+	uid := util.Int642String(userId)
+	parser := jwt.NewParser()
+	var owned []string
+	for _, key := range keys {
+		claims := new(cm.GFClaims)
+		if _, _, pe := parser.ParseUnverified(strings.TrimPrefix(key, "jwt:"), claims); pe != nil {
+			continue
+		}
+		if claims.UserId == uid {
+			owned = append(owned, key)
+		}
+	}
+	if len(owned) == 0 {
+		return 0, nil
+	}
+	if err = cs.Del(owned...); err != nil {
+		return 0, err
+	}
+	return len(owned), nil
+}
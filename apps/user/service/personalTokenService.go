@@ -0,0 +1,115 @@
+package service
+
+/*
+ * @Desc: 个人访问令牌
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/apps/user/dao"
+	"github.com/GoFurry/gofurry-user/apps/user/models"
+	"github.com/GoFurry/gofurry-user/common"
+	ca "github.com/GoFurry/gofurry-user/common/abstract"
+	"github.com/GoFurry/gofurry-user/common/log"
+	cm "github.com/GoFurry/gofurry-user/common/models"
+	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/common/util"
+)
+
+// CreatePersonalToken 创建个人访问令牌 库中仅保存摘要
+func (svc *userService) CreatePersonalToken(ctx context.Context, userId int64, req models.CreateTokenRequest) (resp models.CreateTokenResponse, err common.GFError) {
+	reqErr := ca.ValidateServiceApi.Validate(req)
+	if reqErr != nil {
+		return resp, common.NewServiceError("入参有误: " + reqErr[0].ErrMsg)
+	}
+	scopes := strings.Fields(req.Scopes)
+	if len(scopes) == 0 {
+		return resp, common.NewServiceError("入参有误: 至少需要一个scope")
+	}
+	count, err := dao.GetUserTokenDao().CountByUserId(userId)
+	if err != nil {
+		return resp, common.NewServiceError("查询令牌失败")
+	}
+	if count >= common.PAT_LIMIT {
+		return resp, common.NewServiceError("令牌数量已达上限")
+	}
+
+	secret := util.GenerateSecureToken(32)
+	token := common.PAT_PREFIX + secret
+	record := models.GfUserToken{
+		UserID:      userId,
+		TokenHash:   util.CreateSHA256(token),
+		TokenPrefix: token[:len(common.PAT_PREFIX)+6],
+		Scopes:      strings.Join(scopes, " "),
+	}
+	record.SetNewId()
+	record.SetName(req.Name)
+	record.CreateTime = cm.LocalTime(time.Now())
+	if req.ExpireDays > 0 {
+		expire := cm.LocalTime(time.Now().AddDate(0, 0, req.ExpireDays))
+		record.ExpireTime = &expire
+	}
+	if err = dao.GetUserTokenDao().WithContext(ctx).Add(&record); err != nil {
+		return resp, common.NewServiceError("令牌入库失败.")
+	}
+	return models.CreateTokenResponse{Token: token, Record: record}, nil
+}
+
+// ListPersonalTokens 查询未撤销的令牌
+func (svc *userService) ListPersonalTokens(userId int64) ([]models.GfUserToken, common.GFError) {
+	records, err := dao.GetUserTokenDao().FindByUserId(userId)
+	if err != nil {
+		return nil, common.NewServiceError("查询令牌失败")
+	}
+	return records, nil
+}
+
+// RevokePersonalToken 撤销令牌 只能撤销自己的令牌
+func (svc *userService) RevokePersonalToken(userId int64, id int64) common.GFError {
+	affected, err := dao.GetUserTokenDao().Revoke(id, userId)
+	if err != nil {
+		return common.NewServiceError("撤销令牌失败")
+	}
+	if affected == 0 {
+		return common.NewServiceError("令牌不存在")
+	}
+	return nil
+}
+
+// ValidatePersonalToken 校验个人访问令牌 并记录最后使用时间
+func (svc *userService) ValidatePersonalToken(ctx context.Context, token string) (*models.GfUserToken, *models.GfUser, common.GFError) {
+	if !strings.HasPrefix(token, common.PAT_PREFIX) {
+		return nil, nil, common.NewServiceError("令牌格式有误.")
+	}
+	record, err := dao.GetUserTokenDao().FindOneByHash(util.CreateSHA256(token))
+	if err != nil || record.Revoked {
+		return nil, nil, common.NewServiceError("令牌无效.")
+	}
+	if record.ExpireTime != nil && time.Time(*record.ExpireTime).Before(time.Now()) {
+		return nil, nil, common.NewServiceError("令牌已过期.")
+	}
+	user, err := svc.GetUser(ctx, record.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Status == "banned" {
+		return nil, nil, common.NewServiceError("该用户已被封禁")
+	}
+
+	// 每分钟最多回写一次最后使用时间
+	if cs.SetNXCtx(ctx, "pat:used:"+strconv.FormatInt(record.ID, 10), 1, time.Minute) {
+		id := record.ID
+		util.RunAsync(func() {
+			if err := dao.GetUserTokenDao().TouchLastUsed(id, time.Now()); err != nil {
+				log.ErrorCtx(ctx, "令牌使用时间更新失败: ", err.GetMsg())
+			}
+		})
+	}
+	return &record, &user, nil
+}
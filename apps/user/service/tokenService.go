@@ -0,0 +1,36 @@
+package service
+
+/*
+ * @Desc: 登录凭证校验
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"strings"
+
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/log"
+	cm "github.com/GoFurry/gofurry-user/common/models"
+	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/common/util"
+)
+
+// ValidateSessionToken 校验 util.NewToken 签发的登录凭证 须存在于 redis 且签名有效
+func (svc *userService) ValidateSessionToken(ctx context.Context, token string) (*cm.GFClaims, common.GFError) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, common.NewServiceError("用户未登录.")
+	}
+	cache, err := cs.GetStringCtx(ctx, "jwt:"+token)
+	if err != nil || cache == "" {
+		return nil, common.NewServiceError("登录信息已过期.")
+	}
+	claims, pe := util.ParseToken(cache)
+	if pe != nil || claims == nil {
+		log.DebugCtx(ctx, "解析Token失败: ", pe)
+		return nil, common.NewServiceError("用户登录信息失效.")
+	}
+	return claims, nil
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"math/rand"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/GoFurry/gofurry-user/apps/user/dao"
@@ -10,6 +12,7 @@ import (
 	"github.com/GoFurry/gofurry-user/common"
 	ca "github.com/GoFurry/gofurry-user/common/abstract"
 	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/common/metrics"
 	cm "github.com/GoFurry/gofurry-user/common/models"
 	cs "github.com/GoFurry/gofurry-user/common/service"
 	"github.com/GoFurry/gofurry-user/common/util"
@@ -28,19 +31,26 @@ var Avatars = []string{"龙", "虎", "狼"}
 
 // Login 用户登录
 func (svc *userService) Login(c *fiber.Ctx, req models.UserLoginRequest) (tokenStr string, err common.GFError) {
+	reason := metrics.REASON_OK
+	defer func() { metrics.AuthEvent("login", reason) }()
+
 	// 检验入参合法性
 	errorResults := ca.ValidateServiceApi.Validate(req)
 	if errorResults != nil {
+		reason = "invalid_request"
 		return tokenStr, common.NewServiceError("传入参数有误")
 	}
+	ctx := c.UserContext()
 	// 查找是否有该用户,支持账户名和邮箱登录
-	userRecord, err := dao.GetUserDao().FindOneByEmail(req.Name)
+	userRecord, err := dao.GetUserDao().WithContext(ctx).FindOneByEmail(req.Name)
 	if err != nil {
+		reason = "user_not_found"
 		return "", common.NewServiceError("未找到该邮箱的账户记录.")
 	}
 
 	// 用户是否被封禁
 	if userRecord.Status == "banned" {
+		reason = "banned"
 		return "", common.NewServiceError("该用户已被封禁")
 	}
 
@@ -54,13 +64,15 @@ func (svc *userService) Login(c *fiber.Ctx, req models.UserLoginRequest) (tokenS
 	// 校验密码
 	password := util.CreateMD5(decryptPassword + env.GetServerConfig().Auth.AuthSalt)
 	if password != userRecord.Password {
+		reason = "wrong_password"
 		err = common.NewServiceError("密码错误.")
 		return
 	}
 	// 生成 token 存 redis
 	tokenStr, tokenErr := util.NewToken(strconv.FormatInt(userRecord.ID, 10), userRecord.Name)
 	if tokenErr != nil {
-		log.Error(tokenErr)
+		log.ErrorCtx(ctx, tokenErr)
+		reason = "token_error"
 		err = common.NewServiceError("创建Token错误.")
 		return
 	}
@@ -75,13 +87,13 @@ func (svc *userService) Login(c *fiber.Ctx, req models.UserLoginRequest) (tokenS
 	}
 	newLoginLog.SetNewId()
 
-	err = dao.GetUserLogDao().Add(newLoginLog)
+	err = dao.GetUserLogDao().WithContext(ctx).Add(newLoginLog)
 	if err != nil {
-		log.Error("登录记录入库失败: ", err)
+		log.ErrorCtx(ctx, "登录记录入库失败: ", err)
 	}
 
 	// token 存 redis
-	cs.SetExpire("jwt:"+tokenStr, tokenStr, common.JWT_RELET_NUM*time.Hour)
+	cs.SetExpireCtx(ctx, "jwt:"+tokenStr, tokenStr, common.JWT_RELET_NUM*time.Hour)
 	currentUser := models.CurrentUser{
 		ID:   userRecord.ID,
 		Name: userRecord.Name,
@@ -92,20 +104,30 @@ func (svc *userService) Login(c *fiber.Ctx, req models.UserLoginRequest) (tokenS
 }
 
 // Register 用户注册
-func (svc *userService) Register(req models.UserRegisterRequest) (err common.GFError) {
+func (svc *userService) Register(ctx context.Context, req models.UserRegisterRequest) (err common.GFError) {
+	reason := metrics.REASON_OK
+	defer func() { metrics.AuthEvent("register", reason) }()
+
+	if !env.GetServerConfig().Feature.RegisterEnabled() {
+		reason = "register_closed"
+		return common.NewServiceError("暂未开放注册")
+	}
 	// 入参校验
 	reqErr := ca.ValidateServiceApi.Validate(req)
 	if reqErr != nil {
+		reason = "invalid_request"
 		return common.NewServiceError("入参有误: " + reqErr[0].ErrMsg)
 	}
 	// 注册查重
 	_, err = dao.GetUserDao().FindOneByEmail(req.Email)
 	if err == nil {
+		reason = "email_taken"
 		return common.NewServiceError("邮箱已被注册")
 	}
 	// 校对验证码
-	code, err := cs.GetString("email:" + req.Email)
+	code, err := cs.GetStringCtx(ctx, "email:"+req.Email)
 	if code != util.CreateMD5(req.Code+env.GetServerConfig().Auth.AuthSalt) || err != nil {
+		reason = "invalid_code"
 		return common.NewServiceError("邮箱验证码错误")
 	}
 
@@ -133,9 +155,58 @@ func (svc *userService) Register(req models.UserRegisterRequest) (err common.GFE
 	defaultInfo := "暂无个人简介."
 	userTab.Info = &defaultInfo
 
-	err = dao.GetUserDao().Add(userTab)
+	err = dao.GetUserDao().WithContext(ctx).Add(userTab)
 	if err != nil {
+		reason = "db_error"
 		return common.NewServiceError("注册记录入库失败.")
 	}
 	return nil
 }
+
+// GetUser 查询用户
+func (svc *userService) GetUser(ctx context.Context, id int64) (record models.GfUser, err common.GFError) {
+	err = dao.GetUserDao().WithContext(ctx).GetById(id, &record)
+	if err != nil {
+		if err.GetMsg() == common.RETURN_RECORD_NOT_FOUND {
+			return record, common.NewServiceError("用户不存在")
+		}
+		return record, common.NewServiceError("查询用户失败")
+	}
+	return record, nil
+}
+
+// BatchGetUsers 批量查询用户 未找到的ID忽略
+func (svc *userService) BatchGetUsers(ids []int64) ([]models.GfUser, common.GFError) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > common.BATCH_QUERY_LIMIT {
+		return nil, common.NewServiceError("单次查询数量超出上限")
+	}
+	records, err := dao.GetUserDao().FindByIds(ids)
+	if err != nil {
+		return nil, common.NewServiceError("查询用户失败")
+	}
+	return records, nil
+}
+
+// CheckPermission 按用户角色校验权限 封禁用户一律拒绝
+func (svc *userService) CheckPermission(ctx context.Context, id int64, permission string) (allowed bool, reason string, err common.GFError) {
+	record, err := svc.GetUser(ctx, id)
+	if err != nil {
+		return false, "", err
+	}
+	if record.Status == "banned" {
+		return false, "该用户已被封禁", nil
+	}
+	for _, p := range env.GetServerConfig().Auth.RolePermissions[record.Role] {
+		if p == "*" || p == permission {
+			return true, "", nil
+		}
+		// 前缀通配 如 user:*
+		if strings.HasSuffix(p, ":*") && strings.HasPrefix(permission, strings.TrimSuffix(p, "*")) {
+			return true, "", nil
+		}
+	}
+	return false, "角色无此权限", nil
+}
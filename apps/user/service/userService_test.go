@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/GoFurry/gofurry-user/apps/user/models"
+	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/GoFurry/gofurry-user/roof/db"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// setup 使用 conf/server.yaml 中的 sqlite 内存库 redis 由 miniredis 替代
+func setup(t *testing.T) {
+	t.Helper()
+	if err := db.Orm.MigrateOnStart(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	env.GetServerConfig().Redis.RedisAddr = miniredis.RunT(t).Addr()
+	cs.InitRedisOnStart()
+}
+
+// 注册 登录 校验登录凭证 再创建并使用个人访问令牌
+func TestRegisterLoginFlow(t *testing.T) {
+	setup(t)
+	ctx := context.Background()
+	svc := GetUserService()
+
+	email := "fox@example.com"
+	cs.SetExpireCtx(ctx, "email:"+email, util.CreateMD5("123456"+env.GetServerConfig().Auth.AuthSalt), 0)
+	req := models.UserRegisterRequest{Email: email, Name: "fox", Password: "secret", Code: "123456", Role: "user"}
+	if err := svc.Register(ctx, req); err != nil {
+		t.Fatalf("register: %s", err.GetMsg())
+	}
+	if err := svc.Register(ctx, req); err == nil || err.GetMsg() != "邮箱已被注册" {
+		t.Fatalf("duplicate register: %v", err)
+	}
+
+	app := fiber.New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+	if _, err := svc.Login(c, models.UserLoginRequest{Name: email, Password: "wrong"}); err == nil {
+		t.Fatal("login with wrong password succeeded")
+	}
+	token, err := svc.Login(c, models.UserLoginRequest{Name: email, Password: "secret"})
+	if err != nil {
+		t.Fatalf("login: %s", err.GetMsg())
+	}
+	claims, err := svc.ValidateSessionToken(ctx, token)
+	if err != nil {
+		t.Fatalf("validate session: %s", err.GetMsg())
+	}
+	userId, _ := strconv.ParseInt(claims.UserId, 10, 64)
+	user, err := svc.GetUser(ctx, userId)
+	if err != nil || user.Email == nil || *user.Email != email {
+		t.Fatalf("GetUser(%d) = %+v, %v", userId, user, err)
+	}
+
+	created, err := svc.CreatePersonalToken(ctx, userId, models.CreateTokenRequest{Name: "ci", Scopes: "user:read"})
+	if err != nil {
+		t.Fatalf("create token: %s", err.GetMsg())
+	}
+	record, owner, err := svc.ValidatePersonalToken(ctx, created.Token)
+	if err != nil {
+		t.Fatalf("validate token: %s", err.GetMsg())
+	}
+	if owner.ID != userId || record.Scopes != "user:read" {
+		t.Fatalf("token owner %d scopes %q", owner.ID, record.Scopes)
+	}
+	if err = svc.RevokePersonalToken(userId, record.ID); err != nil {
+		t.Fatalf("revoke token: %s", err.GetMsg())
+	}
+	if _, _, err = svc.ValidatePersonalToken(ctx, created.Token); err == nil {
+		t.Fatal("revoked token still valid")
+	}
+}
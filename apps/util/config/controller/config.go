@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"github.com/GoFurry/gofurry-user/apps/util/config/service"
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+ * @Desc: 配置查询
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+type configApi struct{}
+
+var ConfigApi *configApi
+
+func init() {
+	ConfigApi = &configApi{}
+}
+
+// @Summary 生效配置
+// @Schemes
+// @Description 配置文件 环境变量与 etcd 动态配置合并后的结果 敏感字段已脱敏 需 config:read 权限
+// @Tags Util-config
+// @Produce json
+// @Success 200 {object} common.ResultData{data=service.EffectiveConfig}
+// @Router /api/util/config [Get]
+func (api *configApi) Effective(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return common.NewResponse(c).SuccessWithData(service.GetConfigService().Effective())
+}
+
+// @Summary 业务开关
+// @Schemes
+// @Description 是否开放注册 启用的三方登录与功能开关 供前端展示
+// @Tags Util-config
+// @Produce json
+// @Success 200 {object} common.ResultData{data=service.Features}
+// @Router /api/util/features [Get]
+func (api *configApi) Features(c *fiber.Ctx) error {
+	return common.NewResponse(c).SuccessWithData(service.GetConfigService().Features())
+}
@@ -0,0 +1,66 @@
+package service
+
+/*
+ * @Desc: 生效配置查询
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"github.com/GoFurry/gofurry-user/roof/env"
+)
+
+type configService struct{}
+
+var configSingleton = new(configService)
+
+func GetConfigService() *configService { return configSingleton }
+
+// EffectiveConfig 配置文件 环境变量与 etcd 动态配置合并后的结果
+type EffectiveConfig struct {
+	Config  map[string]any    `json:"config"`  // 生效配置 敏感字段已脱敏
+	Dynamic map[string]string `json:"dynamic"` // 来自 etcd 的配置项 键为 yaml 路径
+	Prefix  string            `json:"prefix"`  // etcd 键前缀 未开启动态配置时为空
+	File    string            `json:"file"`    // 配置文件路径 仅使用环境变量时为空
+}
+
+// Features 前端可见的业务开关
+type Features struct {
+	RegisterOpen   bool            `json:"register_open"`
+	OauthProviders []string        `json:"oauth_providers"`
+	Toggles        map[string]bool `json:"toggles"`
+}
+
+// Effective 当前生效的配置
+func (svc *configService) Effective() EffectiveConfig {
+	conf := env.GetServerConfig()
+	result := EffectiveConfig{
+		Config:  conf.MaskedMap(),
+		Dynamic: env.DynamicValues(),
+		File:    env.ConfigFile(),
+	}
+	if conf.Dynamic.IsOn == "on" {
+		result.Prefix = env.DynamicPrefix()
+	}
+	return result
+}
+
+// Features 当前业务开关 未限定三方登录时返回全部支持的平台
+func (svc *configService) Features() Features {
+	feature := env.GetServerConfig().Feature
+	providers := make([]string, 0, 2)
+	for _, provider := range []string{"github", "gitee"} {
+		if feature.OauthEnabled(provider) {
+			providers = append(providers, provider)
+		}
+	}
+	toggles := make(map[string]bool, len(feature.Toggles))
+	for name, on := range feature.Toggles {
+		toggles[name] = on
+	}
+	return Features{
+		RegisterOpen:   feature.RegisterEnabled(),
+		OauthProviders: providers,
+		Toggles:        toggles,
+	}
+}
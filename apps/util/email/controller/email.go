@@ -31,7 +31,7 @@ func init() {
 // @Router /api/util/email/send [Get]
 func (api *emailApi) Send(c *fiber.Ctx) error {
 	email := c.Query("email")
-	err := service.GetEmailService().SendEmail(email)
+	err := service.GetEmailService().SendEmail(c.UserContext(), email)
 	if err != nil {
 		return common.NewResponse(c).Error(err)
 	}
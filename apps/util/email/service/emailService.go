@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"time"
 
 	"github.com/GoFurry/gofurry-user/common"
@@ -18,7 +19,7 @@ var emailSingleton = new(emailService)
 func GetEmailService() *emailService { return emailSingleton }
 
 // 发送邮箱验证码
-func (svc *emailService) SendEmail(email string) common.GFError {
+func (svc *emailService) SendEmail(ctx context.Context, email string) common.GFError {
 	// 入参校验
 	req := struct {
 		Email string `validate:"required,email,min=1,max=100" label:"邮箱" json:"email"`
@@ -26,7 +27,7 @@ func (svc *emailService) SendEmail(email string) common.GFError {
 
 	errorResults := ca.ValidateServiceApi.Validate(req)
 	if len(errorResults) > 0 {
-		log.Warn("(svc *emailService) SendEmail 入参有误")
+		log.WarnCtx(ctx, "(svc *emailService) SendEmail 入参有误")
 		return common.NewServiceError(errorResults[0].ErrMsg)
 	}
 
@@ -37,6 +38,6 @@ func (svc *emailService) SendEmail(email string) common.GFError {
 	}
 	// 邮件验证码存redis
 	code = util.CreateMD5(code + env.GetServerConfig().Auth.AuthSalt)
-	_ = cs.SetExpire("email:"+email, code, 300*time.Second)
+	_ = cs.SetExpireCtx(ctx, "email:"+email, code, 300*time.Second)
 	return nil
 }
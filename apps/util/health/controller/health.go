@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/GoFurry/gofurry-user/apps/util/health/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+ * @Desc: 健康检查
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+type healthApi struct{}
+
+var HealthApi *healthApi
+
+func init() {
+	HealthApi = &healthApi{}
+}
+
+// @Summary 存活检查
+// @Schemes
+// @Description 进程存活即返回 200 不检查依赖
+// @Tags Util-health
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /healthz [Get]
+func (api *healthApi) Healthz(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// @Summary 就绪检查
+// @Schemes
+// @Description 检查数据库 Redis etcd 与 gRPC 依赖 启动中与退出中返回 503
+// @Tags Util-health
+// @Produce json
+// @Success 200 {object} service.ReadyResult
+// @Failure 503 {object} service.ReadyResult
+// @Router /readyz [Get]
+func (api *healthApi) Readyz(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	ready, result := service.GetHealthService().Ready()
+	if !ready {
+		return c.Status(http.StatusServiceUnavailable).JSON(result)
+	}
+	return c.JSON(result)
+}
@@ -0,0 +1,148 @@
+package service
+
+/*
+ * @Desc: 健康检查
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/GoFurry/gofurry-user/roof/db"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"google.golang.org/grpc/connectivity"
+)
+
+const (
+	STATE_STARTING = "starting" // 启动中
+	STATE_READY    = "ready"    // 可接收流量
+	STATE_STOPPING = "stopping" // 退出中
+
+	checkTimeout = 2 * time.Second // 单个依赖的检查超时
+)
+
+// 依赖的 gRPC 服务
+var grpcDependencies = []string{"github-oauth-service"}
+
+type healthService struct {
+	state atomic.Value
+}
+
+var healthSingleton = &healthService{}
+
+func init() {
+	healthSingleton.state.Store(STATE_STARTING)
+}
+
+func GetHealthService() *healthService { return healthSingleton }
+
+// DependencyStatus 单个依赖的检查结果
+type DependencyStatus struct {
+	Status  string `json:"status"`          // up down
+	Latency string `json:"latency"`         // 检查耗时
+	Error   string `json:"error,omitempty"` // 失败原因
+}
+
+// ReadyResult 就绪检查结果
+type ReadyResult struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyStatus `json:"checks,omitempty"`
+}
+
+// SetState 由启动与退出流程设置
+func (svc *healthService) SetState(state string) {
+	svc.state.Store(state)
+}
+
+// State 当前状态
+func (svc *healthService) State() string {
+	return svc.state.Load().(string)
+}
+
+// Ready 检查各依赖 启动中与退出中直接返回未就绪
+func (svc *healthService) Ready() (bool, ReadyResult) {
+	if state := svc.State(); state != STATE_READY {
+		return false, ReadyResult{Status: state}
+	}
+
+	// 数据库以驱动名展示 如 postgres sqlite
+	checks := map[string]func(context.Context) error{
+		env.GetServerConfig().DataBase.DriverName(): db.Orm.Ping,
+		"redis": cs.PingRedis,
+	}
+	if env.GetServerConfig().Etcd.EtcdHost != "" {
+		checks["etcd"] = cs.PingEtcd
+	}
+	for _, name := range grpcDependencies {
+		checks[name] = grpcCheck(name)
+	}
+
+	result := ReadyResult{Status: STATE_READY, Checks: make(map[string]DependencyStatus, len(checks))}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+			status := runCheck(check)
+			mu.Lock()
+			result.Checks[name] = status
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, status := range result.Checks {
+		if status.Status != "up" {
+			ready = false
+		}
+	}
+	if !ready {
+		result.Status = "not_ready"
+	}
+	return ready, result
+}
+
+// 带超时执行检查
+func runCheck(check func(context.Context) error) DependencyStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	status := DependencyStatus{Status: "up", Latency: time.Since(start).String()}
+	if err != nil {
+		status.Status = "down"
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// gRPC 连接处于就绪或空闲视为可用
+func grpcCheck(serviceName string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		state, err := util.GrpcConnState(serviceName)
+		if err != nil {
+			return err
+		}
+		if state != connectivity.Ready && state != connectivity.Idle {
+			return fmt.Errorf("连接状态: %s", state)
+		}
+		return nil
+	}
+}
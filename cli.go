@@ -0,0 +1,368 @@
+package main
+
+/*
+ * @Desc: 运维命令 使用与服务相同的配置 DAO 与 redis 不启动 HTTP 服务
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	im "github.com/GoFurry/gofurry-user/apps/idp/models"
+	is "github.com/GoFurry/gofurry-user/apps/idp/service"
+	us "github.com/GoFurry/gofurry-user/apps/user/service"
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/log"
+	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/GoFurry/gofurry-user/roof/db"
+)
+
+const cliUsage = `用法:
+  gf-user migrate [up]                                 执行未执行的数据库迁移
+  gf-user migrate down [--steps <n>]                   回滚最近的迁移 默认一个版本
+  gf-user migrate status                               查看迁移状态
+  gf-user user create --email <邮箱> [--name <昵称>] [--admin] [--password-stdin]
+                                                       创建用户 未指定密码时随机生成
+  gf-user user reset-password <邮箱> [--password-stdin] 重置密码并撤销会话
+  gf-user user ban <id>                                封禁用户并撤销会话
+  gf-user sessions revoke <id|邮箱|用户名>              撤销用户的全部会话
+  gf-user client create --name <名称> --redirect-uris <地址> [--scopes <scope>] [--public] [--trusted]
+                                                       登记 OAuth 客户端 密钥仅输出一次`
+
+// 命令分组 子命令为空表示无子命令
+var cliCommands = map[string]map[string]func(args []string) error{
+	"migrate": {
+		"":       migrateCommand,
+		"up":     migrateCommand,
+		"down":   migrateDownCommand,
+		"status": migrateStatusCommand,
+	},
+	"user": {
+		"create":         userCreateCommand,
+		"reset-password": userResetPasswordCommand,
+		"ban":            userBanCommand,
+	},
+	"sessions": {
+		"revoke": sessionsRevokeCommand,
+	},
+	"client": {
+		"create": clientCreateCommand,
+	},
+}
+
+// isCliCommand 是否运维命令
+func isCliCommand(name string) bool {
+	_, ok := cliCommands[name]
+	return ok
+}
+
+// runCli 执行运维命令 返回进程退出码
+func runCli(args []string) int {
+	group := cliCommands[args[0]]
+	args = args[1:]
+	cmd, ok := group[""]
+	if len(args) > 0 && args[0] != "" {
+		if sub, found := group[args[0]]; found {
+			cmd, ok = sub, true
+			args = args[1:]
+		}
+	}
+	if !ok {
+		fmt.Println(cliUsage)
+		return 2
+	}
+
+	if !checkConfig() {
+		return 1
+	}
+	defer db.Orm.Close()
+	defer cs.CloseRedis()
+	if err := cmd(args); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			log.Error(err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// cliConnect 参数解析通过后再连接 数据库必需 redis 按需连接
+func cliConnect(withRedis bool) error {
+	if err := db.Orm.Connect(context.Background()); err != nil {
+		return fmt.Errorf("数据库连接失败: %w", err)
+	}
+	if withRedis {
+		cs.InitRedisOnStart()
+	}
+	return nil
+}
+
+// 执行未执行的迁移
+func migrateCommand(args []string) error {
+	if _, err := parseCliArgs(flag.NewFlagSet("migrate", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	if err := cliConnect(false); err != nil {
+		return err
+	}
+	applied, err := db.Orm.Migrate(context.Background())
+	for _, m := range applied {
+		fmt.Println("已执行:", m)
+	}
+	if err != nil {
+		return err
+	}
+	cliAudit("migrate.up", map[string]interface{}{"count": len(applied)})
+	if len(applied) == 0 {
+		fmt.Println("没有需要执行的迁移")
+	}
+	return nil
+}
+
+// 回滚最近的迁移 默认一个版本
+func migrateDownCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "回滚的版本数")
+	if _, err := parseCliArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return errors.New("--steps 须大于 0")
+	}
+	if err := cliConnect(false); err != nil {
+		return err
+	}
+	rolled, err := db.Orm.Rollback(context.Background(), *steps)
+	for _, m := range rolled {
+		fmt.Println("已回滚:", m)
+	}
+	if err != nil {
+		return err
+	}
+	cliAudit("migrate.down", map[string]interface{}{"count": len(rolled)})
+	if len(rolled) == 0 {
+		fmt.Println("没有可回滚的迁移")
+	}
+	return nil
+}
+
+// 迁移执行状态
+func migrateStatusCommand(args []string) error {
+	if _, err := parseCliArgs(flag.NewFlagSet("migrate status", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+	if err := cliConnect(false); err != nil {
+		return err
+	}
+	status, err := db.Orm.MigrationStatus(context.Background())
+	for _, item := range status {
+		state := "未执行"
+		if item.AppliedAt != nil {
+			state = "已执行 " + item.AppliedAt.Format(common.TIME_FORMAT_DATE)
+		}
+		fmt.Printf("%-30s %s\n", item.Migration, state)
+	}
+	return err
+}
+
+func userCreateCommand(args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	email := fs.String("email", "", "邮箱 作为登录账户")
+	name := fs.String("name", "", "昵称 默认取邮箱前缀")
+	admin := fs.Bool("admin", false, "授予 admin 角色")
+	role := fs.String("role", "", "角色 与 --admin 同时使用时以 --admin 为准")
+	passwordStdin := fs.Bool("password-stdin", false, "从标准输入读取密码")
+	if _, err := parseCliArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if !strings.Contains(*email, "@") {
+		return errors.New("--email 格式有误")
+	}
+	if *admin {
+		*role = us.ROLE_ADMIN
+	}
+	password, generated, err := readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+	if err = cliConnect(false); err != nil {
+		return err
+	}
+	record, gfErr := us.GetUserService().CreateUser(*email, *name, password, *role)
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	cliAudit("user.create", map[string]interface{}{"user_id": record.ID, "role": record.Role})
+	fmt.Println("用户已创建 id:", record.ID, "角色:", record.Role)
+	if generated {
+		fmt.Println("初始密码:", password)
+	}
+	return nil
+}
+
+func userResetPasswordCommand(args []string) error {
+	fs := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	passwordStdin := fs.Bool("password-stdin", false, "从标准输入读取密码")
+	positional, err := parseCliArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if err = cliConnect(true); err != nil {
+		return err
+	}
+	record, gfErr := us.GetUserService().FindUser(positional[0])
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	password, generated, err := readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+	revoked, gfErr := us.GetUserService().ResetPassword(record.ID, password)
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	cliAudit("user.reset_password", map[string]interface{}{"user_id": record.ID, "revoked": revoked})
+	fmt.Println("密码已重置 id:", record.ID, "已撤销会话:", revoked)
+	if generated {
+		fmt.Println("新密码:", password)
+	}
+	return nil
+}
+
+func userBanCommand(args []string) error {
+	positional, err := parseCliArgs(flag.NewFlagSet("user ban", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	if err = cliConnect(true); err != nil {
+		return err
+	}
+	record, gfErr := us.GetUserService().FindUser(positional[0])
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	revoked, gfErr := us.GetUserService().BanUser(record.ID)
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	tokens, gfErr := is.GetIdpService().RevokeUserTokens(record.ID)
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	cliAudit("user.ban", map[string]interface{}{"user_id": record.ID, "revoked": revoked + tokens})
+	fmt.Println("用户已封禁 id:", record.ID, "已撤销会话:", revoked, "刷新令牌:", tokens)
+	return nil
+}
+
+func sessionsRevokeCommand(args []string) error {
+	positional, err := parseCliArgs(flag.NewFlagSet("sessions revoke", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	if err = cliConnect(true); err != nil {
+		return err
+	}
+	record, gfErr := us.GetUserService().FindUser(positional[0])
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	revoked, gfErr := us.GetUserService().RevokeSessions(record.ID)
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	tokens, gfErr := is.GetIdpService().RevokeUserTokens(record.ID)
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	cliAudit("sessions.revoke", map[string]interface{}{"user_id": record.ID, "revoked": revoked + tokens})
+	fmt.Println("已撤销会话 id:", record.ID, "登录凭证:", revoked, "刷新令牌:", tokens)
+	return nil
+}
+
+// 登记 OAuth 客户端 多个回调地址与 scope 以空格分隔
+func clientCreateCommand(args []string) error {
+	fs := flag.NewFlagSet("client create", flag.ContinueOnError)
+	name := fs.String("name", "", "客户端名称")
+	redirectUris := fs.String("redirect-uris", "", "回调地址 多个以空格分隔")
+	scopes := fs.String("scopes", "openid profile email", "允许的 scope 多个以空格分隔")
+	public := fs.Bool("public", false, "公开客户端 无密钥 须使用 PKCE")
+	trusted := fs.Bool("trusted", false, "可信客户端 复用登录会话直接授权 仅限自有应用")
+	if _, err := parseCliArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if err := cliConnect(false); err != nil {
+		return err
+	}
+	record, secret, gfErr := is.GetIdpService().CreateClient(im.CreateClientRequest{
+		Name:         *name,
+		RedirectUris: strings.Join(strings.Fields(*redirectUris), " "),
+		Scopes:       strings.Join(strings.Fields(*scopes), " "),
+		Public:       *public,
+		Trusted:      *trusted,
+	})
+	if gfErr != nil {
+		return errors.New(gfErr.GetMsg())
+	}
+	cliAudit("client.create", map[string]interface{}{"client_id": record.ClientID, "public": record.Public, "trusted": record.Trusted})
+	fmt.Println("客户端已登记 client_id:", record.ClientID)
+	if secret != "" {
+		fmt.Println("client_secret:", secret)
+	}
+	return nil
+}
+
+// parseCliArgs 解析参数 选项可位于位置参数前后 位置参数数量须为 n
+func parseCliArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != n {
+		fmt.Println(cliUsage)
+		return nil, flag.ErrHelp
+	}
+	return positional, nil
+}
+
+// readPassword 从标准输入读取密码 未指定时随机生成
+func readPassword(fromStdin bool) (password string, generated bool, err error) {
+	if !fromStdin {
+		return util.GenerateSecureToken(12), true, nil
+	}
+	raw, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", false, fmt.Errorf("读取密码失败: %w", err)
+	}
+	password = strings.TrimRight(string(raw), "\r\n")
+	if password == "" {
+		return "", false, errors.New("密码不能为空")
+	}
+	return password, false, nil
+}
+
+// cliAudit 运维操作写入审计日志
+func cliAudit(action string, fields map[string]interface{}) {
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	fields["source"] = "cli"
+	fields["action"] = action
+	fields["operator"] = os.Getenv("USER")
+	log.Audit(fields, "运维命令")
+}
@@ -1,13 +1,14 @@
 package abstract
 
 import (
+	"context"
 	"errors"
 
 	"github.com/GoFurry/gofurry-user/common"
 	"github.com/GoFurry/gofurry-user/common/log"
 	database "github.com/GoFurry/gofurry-user/roof/db"
-	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 /*
@@ -25,20 +26,21 @@ func (dao *Dao) Init() {
 	dao.Gm = database.Orm.DB()
 }
 
+// WithContext 绑定请求上下文 语句纳入链路追踪 错误日志附带请求 ID
+func (dao *Dao) WithContext(ctx context.Context) Dao {
+	return Dao{Gm: dao.Gm.WithContext(ctx), Mode: dao.Mode}
+}
+
+// Replica 只读查询走副本 未配置副本时使用主库 副本有复制延迟 写后立即读的场景不要使用
+func (dao *Dao) Replica() *gorm.DB {
+	return dao.Gm.Clauses(dbresolver.Use(database.REPLICA))
+}
+
 func (dao *Dao) Add(record any) common.GFError {
 	db := dao.Gm.Create(record)
 	if err := db.Error; err != nil {
-		log.Error(err)
-		pe, ok := err.(*pgconn.PgError)
-		if ok {
-			if pe.Code == "23502" {
-				return common.NewDaoError("必要数据为空，入库失败")
-			}
-			if pe.Code == "23505" {
-				return common.NewDaoError("数据重复，入库失败")
-			}
-		}
-		return common.NewDaoError(err.Error())
+		log.ErrorCtx(dao.Gm.Statement.Context, err)
+		return constraintError(err)
 	}
 	return nil
 }
@@ -46,17 +48,8 @@ func (dao *Dao) Add(record any) common.GFError {
 func (dao *Dao) Update(id int64, record any) (int64, common.GFError) {
 	db := dao.Gm.Omit("create_time", "node").Where("id = ?", id).Updates(record)
 	if err := db.Error; err != nil {
-		log.Error(err)
-		pe, ok := err.(*pgconn.PgError)
-		if ok {
-			if pe.Code == "23502" {
-				return 0, common.NewDaoError("必要数据为空，入库失败")
-			}
-			if pe.Code == "23505" {
-				return 0, common.NewDaoError("数据重复，入库失败")
-			}
-		}
-		return 0, common.NewDaoError(err.Error())
+		log.ErrorCtx(dao.Gm.Statement.Context, err)
+		return 0, constraintError(err)
 	}
 	return db.RowsAffected, nil
 }
@@ -64,7 +57,7 @@ func (dao *Dao) Update(id int64, record any) (int64, common.GFError) {
 func (dao *Dao) Delete(idList []int64, tableMode any) (int64, common.GFError) {
 	db := dao.Gm.Where("id in ?", idList).Delete(tableMode)
 	if err := db.Error; err != nil {
-		log.Error(err)
+		log.ErrorCtx(dao.Gm.Statement.Context, err)
 		return 0, common.NewDaoError(err.Error())
 	}
 	return db.RowsAffected, nil
@@ -73,7 +66,7 @@ func (dao *Dao) Delete(idList []int64, tableMode any) (int64, common.GFError) {
 func (dao *Dao) GetById(id int64, record any) common.GFError {
 	db := dao.Gm.Where("id = ?", id).Take(record)
 	if err := db.Error; err != nil {
-		log.Error(err)
+		log.ErrorCtx(dao.Gm.Statement.Context, err)
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return common.NewDaoError("404")
 		}
@@ -86,8 +79,23 @@ func (dao *Dao) Count(tableMode any) (int64, common.GFError) {
 	var count int64
 	db := dao.Gm.Model(tableMode).Count(&count)
 	if err := db.Error; err != nil {
-		log.Error(err)
+		log.ErrorCtx(dao.Gm.Statement.Context, err)
 		return 0, common.NewDaoError("统计数量失败.")
 	}
 	return count, nil
 }
+
+// constraintError 约束错误按类型返回 与数据库驱动无关
+func constraintError(err error) common.GFError {
+	switch database.Violation(err) {
+	case database.VIOLATION_NOT_NULL:
+		return common.NewDaoError("必要数据为空，入库失败")
+	case database.VIOLATION_UNIQUE:
+		return common.NewDaoError("数据重复，入库失败")
+	case database.VIOLATION_FOREIGN_KEY:
+		return common.NewDaoError("关联数据不存在，入库失败")
+	case database.VIOLATION_CHECK:
+		return common.NewDaoError("数据校验未通过，入库失败")
+	}
+	return common.NewDaoError(err.Error())
+}
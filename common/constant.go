@@ -8,8 +8,10 @@ package common
 
 // 项目
 const (
-	COMMON_PROJECT_NAME = "gf-user"     // 项目名
-	COMMON_AUTH_CURRENT = "currentUser" // 当前用户
+	COMMON_PROJECT_NAME = "gf-user"      // 项目名
+	COMMON_AUTH_CURRENT = "currentUser"  // 当前用户
+	COMMON_AUTH_SCOPES  = "currentScope" // 当前凭证的权限范围 仅个人访问令牌设置
+	COMMON_REQUEST_ID   = "requestId"    // 请求 ID
 )
 
 // 时间
@@ -28,8 +30,20 @@ const (
 
 // 常量
 const (
-	JWT_RELET_NUM     = 2 // JWT续租时间(小时)
-	EMAIL_CODE_LENGTH = 6 // 邮箱验证码长度
+	JWT_RELET_NUM     = 2         // JWT续租时间(小时)
+	EMAIL_CODE_LENGTH = 6         // 邮箱验证码长度
+	OAUTH_STATE_TTL   = 10        // 三方登录 state 有效期(分钟)
+	BATCH_QUERY_LIMIT = 100       // 批量查询上限
+	SESSION_SCOPE     = "session" // 登录凭证的 scope
+	PAT_PREFIX        = "gfp_"    // 个人访问令牌前缀
+	PAT_LIMIT         = 20        // 每个用户的个人访问令牌上限
+)
+
+// 服务发现
+const (
+	DISCOVERY_ETCD   = "etcd"   // etcd 注册中心 默认
+	DISCOVERY_STATIC = "static" // 配置文件中的固定地址
+	DISCOVERY_DNS    = "dns"    // DNS 解析
 )
 
 // 请求头
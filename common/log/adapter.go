@@ -0,0 +1,164 @@
+package log
+
+/*
+ * @Desc: 第三方日志接入 fiber log 与 gorm 统一输出到 logger
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	fiberlog "github.com/gofiber/fiber/v2/log"
+	"github.com/sirupsen/logrus"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func init() {
+	fiberlog.SetLogger(&fiberLogger{skip: 2})
+}
+
+// fiberLogger 实现 fiber AllLogger
+// 包级函数 fiberlog.Info 经过一层转发 skip 为 2 WithContext 返回的实例直接调用 skip 为 1
+type fiberLogger struct {
+	ctx  context.Context
+	skip int
+}
+
+func (l *fiberLogger) entry() *logrus.Entry {
+	return entryCtx(l.ctx, l.skip+1)
+}
+
+// 键值对转为日志字段
+func (l *fiberLogger) entryw(keysAndValues []interface{}) *logrus.Entry {
+	fields := logrus.Fields{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+	return entryCtx(l.ctx, l.skip+1).WithFields(fields)
+}
+
+func (l *fiberLogger) Trace(v ...interface{}) { l.entry().Trace(v...) }
+func (l *fiberLogger) Debug(v ...interface{}) { l.entry().Debug(v...) }
+func (l *fiberLogger) Info(v ...interface{})  { l.entry().Info(v...) }
+func (l *fiberLogger) Warn(v ...interface{})  { l.entry().Warn(v...) }
+func (l *fiberLogger) Error(v ...interface{}) { l.entry().Error(v...) }
+func (l *fiberLogger) Fatal(v ...interface{}) { l.entry().Fatal(v...) }
+func (l *fiberLogger) Panic(v ...interface{}) { l.entry().Panic(v...) }
+
+func (l *fiberLogger) Tracef(format string, v ...interface{}) { l.entry().Tracef(format, v...) }
+func (l *fiberLogger) Debugf(format string, v ...interface{}) { l.entry().Debugf(format, v...) }
+func (l *fiberLogger) Infof(format string, v ...interface{})  { l.entry().Infof(format, v...) }
+func (l *fiberLogger) Warnf(format string, v ...interface{})  { l.entry().Warnf(format, v...) }
+func (l *fiberLogger) Errorf(format string, v ...interface{}) { l.entry().Errorf(format, v...) }
+func (l *fiberLogger) Fatalf(format string, v ...interface{}) { l.entry().Fatalf(format, v...) }
+func (l *fiberLogger) Panicf(format string, v ...interface{}) { l.entry().Panicf(format, v...) }
+
+func (l *fiberLogger) Tracew(msg string, kv ...interface{}) { l.entryw(kv).Trace(msg) }
+func (l *fiberLogger) Debugw(msg string, kv ...interface{}) { l.entryw(kv).Debug(msg) }
+func (l *fiberLogger) Infow(msg string, kv ...interface{})  { l.entryw(kv).Info(msg) }
+func (l *fiberLogger) Warnw(msg string, kv ...interface{})  { l.entryw(kv).Warn(msg) }
+func (l *fiberLogger) Errorw(msg string, kv ...interface{}) { l.entryw(kv).Error(msg) }
+func (l *fiberLogger) Fatalw(msg string, kv ...interface{}) { l.entryw(kv).Fatal(msg) }
+func (l *fiberLogger) Panicw(msg string, kv ...interface{}) { l.entryw(kv).Panic(msg) }
+
+func (l *fiberLogger) SetLevel(level fiberlog.Level) {
+	switch level {
+	case fiberlog.LevelTrace:
+		logger.SetLevel(logrus.TraceLevel)
+	case fiberlog.LevelDebug:
+		logger.SetLevel(logrus.DebugLevel)
+	case fiberlog.LevelInfo:
+		logger.SetLevel(logrus.InfoLevel)
+	case fiberlog.LevelWarn:
+		logger.SetLevel(logrus.WarnLevel)
+	case fiberlog.LevelError:
+		logger.SetLevel(logrus.ErrorLevel)
+	case fiberlog.LevelFatal:
+		logger.SetLevel(logrus.FatalLevel)
+	case fiberlog.LevelPanic:
+		logger.SetLevel(logrus.PanicLevel)
+	}
+}
+
+func (l *fiberLogger) SetOutput(out io.Writer) { logger.SetOutput(out) }
+
+func (l *fiberLogger) WithContext(ctx context.Context) fiberlog.CommonLogger {
+	return &fiberLogger{ctx: ctx, skip: 1}
+}
+
+// GormLogger gorm 日志输出 慢查询与错误记为 warn 附带语句上下文中的请求 ID
+func GormLogger(config gormlogger.Config) gormlogger.Interface {
+	return gormLogger{config: config}
+}
+
+// 本包函数名前缀
+var logPackage = reflect.TypeOf(gormLogger{}).PkgPath() + "."
+
+type gormLogger struct {
+	config gormlogger.Config
+}
+
+func (l gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	l.config.LogLevel = level
+	return l
+}
+
+func (l gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= gormlogger.Info {
+		l.printf(ctx, msg, data...)
+	}
+}
+
+func (l gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= gormlogger.Warn {
+		l.printf(ctx, msg, data...)
+	}
+}
+
+func (l gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= gormlogger.Error {
+		l.printf(ctx, msg, data...)
+	}
+}
+
+// Trace 错误与慢查询 格式与 gorm 默认日志一致
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.config.LogLevel >= gormlogger.Error &&
+		!(l.config.IgnoreRecordNotFoundError && errors.Is(err, gormlogger.ErrRecordNotFound)):
+	case l.config.SlowThreshold != 0 && elapsed > l.config.SlowThreshold && l.config.LogLevel >= gormlogger.Warn:
+		err = fmt.Errorf("SLOW SQL >= %v", l.config.SlowThreshold)
+	case l.config.LogLevel >= gormlogger.Info:
+	default:
+		return
+	}
+	sql, rows := fc()
+	l.printf(ctx, "%v\n[%.3fms] [rows:%v] %s", err, float64(elapsed.Nanoseconds())/1e6, rows, sql)
+}
+
+func (l gormLogger) printf(ctx context.Context, format string, v ...interface{}) {
+	frame := gormCaller()
+	e := logger.WithFields(buildCallerFields(frame.Function, frame.Line, "gorm"))
+	withContext(e, ctx).Warnf(format, v...)
+}
+
+// gormCaller 跳过 gorm 与本包 取发起查询的调用方 通常为 dao
+func gormCaller() runtime.Frame {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !more || !(strings.HasPrefix(frame.Function, "gorm.io/") || strings.HasPrefix(frame.Function, logPackage)) {
+			return frame
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package log
+
+/*
+ * @Desc: 请求上下文中的日志字段
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import "context"
+
+type requestIdKey struct{}
+
+// WithRequestId 将请求 ID 写入上下文 由 RequestId 中间件调用
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// RequestId 当前上下文的请求 ID 不在请求中时为空
+func RequestId(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
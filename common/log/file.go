@@ -0,0 +1,115 @@
+package log
+
+/*
+ * @Desc: 日志文件输出 error 及以上级别另写一份
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/sirupsen/logrus"
+)
+
+var fileWriters []*RotateWriter
+
+// 按 log_path 配置文件输出 未配置时仅输出到标准输出
+func initFileOutput(conf env.LogConfig) error {
+	if strings.TrimSpace(conf.LogPath) == "" {
+		return nil
+	}
+	rotateConf := RotateConfig{
+		RotationTime: conf.LogRotationTime,
+		MaxSize:      int64(conf.LogMaxSize) << 20,
+		MaxBackups:   conf.LogRotationCount,
+		MaxAge:       time.Duration(conf.LogMaxAge) * 24 * time.Hour,
+		Compress:     conf.LogCompress == "on",
+	}
+	switch rotateConf.RotationTime {
+	case "":
+		rotateConf.RotationTime = ROTATE_DAILY
+	case "off":
+		rotateConf.RotationTime = ""
+	case ROTATE_DAILY, ROTATE_HOURLY:
+	default:
+		return fmt.Errorf("不支持的日志切割周期: %s", conf.LogRotationTime)
+	}
+
+	writer, err := NewRotateWriter(conf.LogPath, rotateConf)
+	if err != nil {
+		return err
+	}
+	errorPath := conf.LogErrorPath
+	if errorPath == "" {
+		ext := filepath.Ext(conf.LogPath)
+		errorPath = strings.TrimSuffix(conf.LogPath, ext) + "-error" + ext
+	}
+	errorWriter, err := NewRotateWriter(errorPath, rotateConf)
+	if err != nil {
+		_ = writer.Close()
+		return err
+	}
+	fileWriters = []*RotateWriter{writer, errorWriter}
+
+	if conf.LogStdout == "on" {
+		logger.SetOutput(io.MultiWriter(os.Stdout, writer))
+	} else {
+		logger.SetOutput(writer)
+	}
+	logger.AddHook(&levelFileHook{writer: errorWriter, levels: []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}})
+	return nil
+}
+
+// Reopen 重新打开日志文件 配合外部 logrotate 收到 SIGHUP 时调用
+func Reopen() error {
+	var errs []error
+	for _, writer := range fileWriters {
+		errs = append(errs, writer.Reopen())
+	}
+	return errors.Join(errs...)
+}
+
+// Close 关闭日志文件 退出前调用 之后若仍有写入会重新打开文件
+func Close() error {
+	var errs []error
+	for _, writer := range fileWriters {
+		errs = append(errs, writer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// levelFileHook 指定级别的日志额外写入文件
+type levelFileHook struct {
+	writer io.Writer
+	levels []logrus.Level
+}
+
+func (h *levelFileHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+func (h *levelFileHook) Fire(entry *logrus.Entry) error {
+	// 钩子在 logger 加锁期间执行 不复用 entry.Buffer
+	line, err := entry.Logger.Formatter.Format(&logrus.Entry{
+		Logger:  entry.Logger,
+		Data:    entry.Data,
+		Time:    entry.Time,
+		Level:   entry.Level,
+		Caller:  entry.Caller,
+		Message: entry.Message,
+		Context: entry.Context,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = h.writer.Write(line)
+	return err
+}
@@ -8,89 +8,81 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	stdlog "log"
+	"os"
 	"runtime"
 	"strings"
 
+	"github.com/GoFurry/gofurry-user/common/tracing"
 	"github.com/GoFurry/gofurry-user/roof/env"
 	"github.com/bytedance/sonic"
 	"github.com/sirupsen/logrus"
 )
 
-var logName = env.GetServerConfig().Log.LogPath
 var logger = logrus.New()
 
 const sFunctionName = "s-FunctionName"
 const sFunctionLine = "s-FunctionLine"
 const sFunctionEvent = "s-Event"
 
+// 日志字段
+const (
+	FIELD_REQUEST_ID = "request_id"
+	FIELD_TRACE_ID   = "trace_id"
+)
+
 func init() {
+	conf := env.GetServerConfig().Log
+	logger.SetOutput(os.Stdout)
+	if strings.ToLower(conf.LogFormat) == "json" {
+		logger.SetFormatter(&JsonFormatter{})
+	} else {
+		logger.SetFormatter(&LoggerFormatter{})
+	}
+	SetLevel(conf.LogLevel)
+	// 日志级别支持热更新
+	env.OnReload(func() { SetLevel(env.GetServerConfig().Log.LogLevel) })
+	if err := initFileOutput(conf); err != nil {
+		fmt.Fprintln(os.Stderr, "日志文件初始化失败 仅输出到标准输出:", err)
+	}
+
+	// 标准库 log 统一输出到 logger
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(Writer(logrus.InfoLevel))
+}
+
+// SetLevel 设置日志级别 debug info warn error 无法识别时为 info
+func SetLevel(level string) {
+	lv, err := logrus.ParseLevel(strings.ToLower(strings.TrimSpace(level)))
+	if err != nil {
+		lv = logrus.InfoLevel
+	}
+	logger.SetLevel(lv)
+}
+
+// SetOutput 设置日志输出
+func SetOutput(out io.Writer) {
+	logger.SetOutput(out)
+}
 
-	//writer, err := rotatelogs.New(
-	//	logName+".%Y%m%d.log",
-	//	rotatelogs.WithLinkName(logName),
-	//	rotatelogs.WithRotationTime(24*time.Hour),
-	//	rotatelogs.WithRotationCount(uint(env.GetServerConfig().Log.LogRotationCount)),
-	//	//rotatelogs.WithMaxAge(time.Hour*24),
-	//)
-	//if err != nil {
-	//	logger.Errorf("config local file business for logger error: %v", err)
-	//} else {
-	//	lfHook := lfshook.NewHook(lfshook.WriterMap{
-	//		logrus.DebugLevel: writer,
-	//		logrus.InfoLevel:  writer,
-	//		logrus.WarnLevel:  writer,
-	//		logrus.ErrorLevel: writer,
-	//		logrus.FatalLevel: writer,
-	//		logrus.PanicLevel: writer,
-	//	}, // 分割日志样式
-	//		//&logrus.TextFormatter{
-	//		//	TimestampFormat: common.TIME_FORMAT_DATE,
-	//		//	DisableColors: true,
-	//		//	FullTimestamp: true,
-	//		//	DisableSorting: true,
-	//		//}
-	//		&LoggerFormatter{},
-	//	)
-	//	logger.SetFormatter(&LoggerFormatter{})
-	//	logger.AddHook(lfHook)
-	//	logLevel := strings.ToLower(env.GetServerConfig().Log.LogLevel)
-	//	switch logLevel {
-	//	case "info":
-	//		logger.SetLevel(logrus.InfoLevel)
-	//	case "debug":
-	//		logger.SetLevel(logrus.DebugLevel)
-	//	case "warn":
-	//		logger.SetLevel(logrus.WarnLevel)
-	//	case "error":
-	//		logger.SetLevel(logrus.ErrorLevel)
-	//	}
-	//}
+// Writer 按指定级别写入日志的 io.Writer 用于接入第三方库
+func Writer(level logrus.Level) *io.PipeWriter {
+	return logger.WriterLevel(level)
 }
 
 type LoggerFormatter struct {
 }
 
 func (l *LoggerFormatter) Format(entry *logrus.Entry) ([]byte, error) {
-	b := &bytes.Buffer{}
-	if entry.Buffer != nil {
-		b = entry.Buffer
-	} else {
+	b := entry.Buffer
+	if b == nil {
 		b = &bytes.Buffer{}
 	}
-	timestamp := entry.Time.Format("2005-01-02 15:04:05:05.999")
-	funcName := entry.Data[sFunctionName]
-	if funcName == nil {
-		funcName = "F"
-	}
-	funcLine := entry.Data[sFunctionLine]
-	if funcLine == nil {
-		funcLine = 0
-	}
-	funcEvent := entry.Data[sFunctionEvent]
-	if funcEvent == nil {
-		funcEvent = env.GetServerConfig().Server.AppName
-	}
+	timestamp := entry.Time.Format("2006-01-02 15:04:05.000")
+	funcName, funcLine, funcEvent := callerOf(entry)
 	targetMap := make(map[string]any)
 	for key, value := range entry.Data {
 		if key == sFunctionName || key == sFunctionLine || key == sFunctionEvent {
@@ -98,22 +90,78 @@ func (l *LoggerFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 		}
 		targetMap[key] = value
 	}
-	newLog := ""
 	dataInfo := ""
 	if len(targetMap) > 0 {
 		dataJson, _ := sonic.Marshal(targetMap)
 		dataInfo = string(dataJson)
 	}
-	msg := entry.Message
-	if len(msg) > env.GetServerConfig().Log.LogChokeLength {
-		msg = msg[0:env.GetServerConfig().Log.LogChokeLength] + "...节流"
-	}
-	newLog = fmt.Sprintf("[%s] [%s] [%s -> %d] [%s -> %s] %s\n",
-		funcEvent, timestamp, funcName, funcLine, entry.Level, msg, dataInfo)
+	newLog := fmt.Sprintf("[%s] [%s] [%s -> %d] [%s -> %s] %s\n",
+		funcEvent, timestamp, funcName, funcLine, entry.Level, chokeMsg(entry.Message), dataInfo)
 	b.WriteString(newLog)
 	return b.Bytes(), nil
 }
 
+// JsonFormatter 每行一个 JSON 对象 便于日志平台采集
+type JsonFormatter struct {
+}
+
+func (j *JsonFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	b := entry.Buffer
+	if b == nil {
+		b = &bytes.Buffer{}
+	}
+	funcName, funcLine, funcEvent := callerOf(entry)
+	data := make(map[string]any, len(entry.Data)+6)
+	for key, value := range entry.Data {
+		if key == sFunctionName || key == sFunctionLine || key == sFunctionEvent {
+			continue
+		}
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		data[key] = value
+	}
+	data["time"] = entry.Time.Format("2006-01-02T15:04:05.000Z07:00")
+	data["level"] = entry.Level.String()
+	data["event"] = funcEvent
+	data["func"] = funcName
+	data["line"] = funcLine
+	data["msg"] = chokeMsg(entry.Message)
+	line, err := sonic.ConfigStd.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	b.Write(line)
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// 调用方信息 第三方库写入时没有调用方字段
+func callerOf(entry *logrus.Entry) (funcName any, funcLine any, funcEvent any) {
+	funcName = entry.Data[sFunctionName]
+	if funcName == nil {
+		funcName = "F"
+	}
+	funcLine = entry.Data[sFunctionLine]
+	if funcLine == nil {
+		funcLine = 0
+	}
+	funcEvent = entry.Data[sFunctionEvent]
+	if funcEvent == nil {
+		funcEvent = env.GetServerConfig().Server.AppName
+	}
+	return
+}
+
+// 超长日志节流
+func chokeMsg(msg string) string {
+	limit := env.GetServerConfig().Log.LogChokeLength
+	if limit > 0 && len(msg) > limit {
+		return msg[0:limit] + "...节流"
+	}
+	return msg
+}
+
 func WithFieldsMsg(fields map[string]interface{}, msg interface{}) {
 	line, functionName := 0, "???"
 	pc, _, line, ok := runtime.Caller(1)
@@ -125,6 +173,18 @@ func WithFieldsMsg(fields map[string]interface{}, msg interface{}) {
 	logger.WithFields(fields).Info(msg)
 }
 
+// Audit 审计日志 event 为 audit
+func Audit(fields map[string]interface{}, msg interface{}) {
+	fields[sFunctionEvent] = "audit"
+	logger.WithFields(fields).Info(msg)
+}
+
+// Access 访问日志 event 为 access
+func Access(fields map[string]interface{}, msg interface{}) {
+	fields[sFunctionEvent] = "access"
+	logger.WithFields(fields).Info(msg)
+}
+
 func buildCallerFields(functionName string, line int, event string) logrus.Fields {
 	if strings.TrimSpace(event) == "" {
 		event = env.GetServerConfig().Server.AppName
@@ -132,38 +192,71 @@ func buildCallerFields(functionName string, line int, event string) logrus.Field
 	return logrus.Fields{sFunctionName: functionName, sFunctionLine: line, sFunctionEvent: event}
 }
 
-func Error(msg ...interface{}) {
+// 调用方字段 skip 为相对调用 entry 的函数的层数
+func entry(skip int) *logrus.Entry {
 	line, functionName := 0, "???"
-	pc, _, line, ok := runtime.Caller(1)
+	pc, _, line, ok := runtime.Caller(skip + 1)
 	if ok {
 		functionName = runtime.FuncForPC(pc).Name()
 	}
-	logger.WithFields(buildCallerFields(functionName, line, "")).Error(msg)
+	return logger.WithFields(buildCallerFields(functionName, line, ""))
 }
 
-func Debug(msg ...interface{}) {
-	line, functionName := 0, "???"
-	pc, _, line, ok := runtime.Caller(1)
-	if ok {
-		functionName = runtime.FuncForPC(pc).Name()
+// 携带上下文时附加 request_id 与 trace_id 便于按请求检索日志
+func entryCtx(ctx context.Context, skip int) *logrus.Entry {
+	return withContext(entry(skip+1), ctx)
+}
+
+func withContext(e *logrus.Entry, ctx context.Context) *logrus.Entry {
+	if ctx == nil {
+		return e
 	}
-	logger.WithFields(buildCallerFields(functionName, line, "")).Debug(msg)
+	fields := logrus.Fields{}
+	if requestId := RequestId(ctx); requestId != "" {
+		fields[FIELD_REQUEST_ID] = requestId
+	}
+	if traceId := tracing.TraceId(ctx); traceId != "" {
+		fields[FIELD_TRACE_ID] = traceId
+	}
+	if len(fields) == 0 {
+		return e
+	}
+	return e.WithFields(fields)
+}
+
+func Error(msg ...interface{}) {
+	entry(1).Error(msg...)
+}
+
+func Debug(msg ...interface{}) {
+	entry(1).Debug(msg...)
 }
 
 func Warn(msg ...interface{}) {
-	line, functionName := 0, "???"
-	pc, _, line, ok := runtime.Caller(1)
-	if ok {
-		functionName = runtime.FuncForPC(pc).Name()
-	}
-	logger.WithFields(buildCallerFields(functionName, line, "")).Warn(msg)
+	entry(1).Warn(msg...)
 }
 
 func Info(msg ...interface{}) {
-	line, functionName := 0, "???"
-	pc, _, line, ok := runtime.Caller(1)
-	if ok {
-		functionName = runtime.FuncForPC(pc).Name()
-	}
-	logger.WithFields(buildCallerFields(functionName, line, "")).Info(msg)
+	entry(1).Info(msg...)
+}
+
+// Fatal 记录后退出进程 仅用于启动阶段
+func Fatal(msg ...interface{}) {
+	entry(1).Fatal(msg...)
+}
+
+func ErrorCtx(ctx context.Context, msg ...interface{}) {
+	entryCtx(ctx, 1).Error(msg...)
+}
+
+func DebugCtx(ctx context.Context, msg ...interface{}) {
+	entryCtx(ctx, 1).Debug(msg...)
+}
+
+func WarnCtx(ctx context.Context, msg ...interface{}) {
+	entryCtx(ctx, 1).Warn(msg...)
+}
+
+func InfoCtx(ctx context.Context, msg ...interface{}) {
+	entryCtx(ctx, 1).Info(msg...)
 }
@@ -0,0 +1,265 @@
+package log
+
+/*
+ * @Desc: 日志文件切割 按时间与大小切割 压缩 按数量与天数保留
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ROTATE_DAILY  = "daily"
+	ROTATE_HOURLY = "hourly"
+
+	backupTimeFormat = "20060102T150405"
+	compressSuffix   = ".gz"
+)
+
+// RotateConfig 切割配置 零值表示不限制
+type RotateConfig struct {
+	RotationTime string        // daily hourly 空则不按时间切割
+	MaxSize      int64         // 单文件最大字节数
+	MaxBackups   int           // 保留的历史文件数
+	MaxAge       time.Duration // 历史文件保留时长
+	Compress     bool          // gzip 压缩历史文件
+}
+
+// RotateWriter 可切割的日志文件 并发安全
+type RotateWriter struct {
+	mu     sync.Mutex
+	path   string
+	conf   RotateConfig
+	file   *os.File
+	size   int64
+	period time.Time // 当前文件所属时间段
+
+	cleanMu sync.Mutex // 压缩与清理串行执行
+}
+
+// NewRotateWriter 打开日志文件 目录不存在时创建
+func NewRotateWriter(path string, conf RotateConfig) (*RotateWriter, error) {
+	w := &RotateWriter{path: path, conf: conf}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Reopen 重新打开文件 外部 logrotate 移走文件后由 SIGHUP 触发
+func (w *RotateWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	return w.open()
+}
+
+// Close 关闭文件
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// 追加方式打开 沿用已有文件的大小与时间段
+func (w *RotateWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	w.period = w.periodOf(info.ModTime())
+	if w.size == 0 {
+		w.period = w.periodOf(time.Now())
+	}
+	return nil
+}
+
+func (w *RotateWriter) shouldRotate(next int64) bool {
+	if w.conf.MaxSize > 0 && w.size > 0 && w.size+next > w.conf.MaxSize {
+		return true
+	}
+	return w.conf.RotationTime != "" && !w.periodOf(time.Now()).Equal(w.period)
+}
+
+// 时间段起点 不按时间切割时为零值
+func (w *RotateWriter) periodOf(t time.Time) time.Time {
+	switch w.conf.RotationTime {
+	case ROTATE_DAILY:
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	case ROTATE_HOURLY:
+		y, m, d := t.Date()
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// 重命名当前文件后新建 压缩与清理在后台进行
+func (w *RotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.period = w.periodOf(time.Now())
+	go w.postRotate(backup)
+	return nil
+}
+
+// 历史文件名 gf-user.log -> gf-user-20060102T150405.log 同一秒内多次切割追加序号
+func (w *RotateWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(w.path)
+	prefix := strings.TrimSuffix(w.path, ext) + "-" + t.Format(backupTimeFormat)
+	name := prefix + ext
+	for i := 1; exists(name) || exists(name+compressSuffix); i++ {
+		name = fmt.Sprintf("%s.%d%s", prefix, i, ext)
+	}
+	return name
+}
+
+func (w *RotateWriter) postRotate(backup string) {
+	w.cleanMu.Lock()
+	defer w.cleanMu.Unlock()
+	if w.conf.Compress {
+		if err := compressFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "压缩日志文件失败 %s: %v\n", backup, err)
+		}
+	}
+	w.removeExpired()
+}
+
+// 按数量与时长清理历史文件
+func (w *RotateWriter) removeExpired() {
+	if w.conf.MaxBackups <= 0 && w.conf.MaxAge <= 0 {
+		return
+	}
+	ext := filepath.Ext(w.path)
+	prefix := filepath.Base(strings.TrimSuffix(w.path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(w.path))
+	if err != nil {
+		return
+	}
+	type backupFile struct {
+		path    string
+		modTime time.Time
+	}
+	var backups []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(name, ext) && !strings.HasSuffix(name, ext+compressSuffix) {
+			continue
+		}
+		// 排除 gf-user-error.log 这类前缀相同的其他日志
+		stamp := strings.TrimPrefix(name, prefix)
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		if _, err := time.ParseInLocation(backupTimeFormat, stamp[:len(backupTimeFormat)], time.Local); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{filepath.Join(filepath.Dir(w.path), name), info.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].modTime.After(backups[j].modTime) })
+	cutoff := time.Now().Add(-w.conf.MaxAge)
+	for i, backup := range backups {
+		if (w.conf.MaxBackups > 0 && i >= w.conf.MaxBackups) || (w.conf.MaxAge > 0 && backup.modTime.Before(cutoff)) {
+			_ = os.Remove(backup.path)
+		}
+	}
+}
+
+// gzip 压缩后删除原文件
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(path + compressSuffix)
+		}
+	}()
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,64 @@
+package metrics
+
+/*
+ * @Desc: gRPC 连接池指标 采集时读取连接池快照
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/connectivity"
+)
+
+var (
+	grpcConnStateDesc = prometheus.NewDesc(namespace+"_grpc_client_connection_state",
+		"gRPC 连接状态 当前状态为 1", []string{"service", "state"}, nil)
+	grpcRequestsDesc = prometheus.NewDesc(namespace+"_grpc_client_requests_total",
+		"gRPC 调用次数", []string{"service", "code"}, nil)
+	grpcLatencyDesc = prometheus.NewDesc(namespace+"_grpc_client_latency_seconds_total",
+		"gRPC 调用累计耗时", []string{"service"}, nil)
+	grpcBreakerDesc = prometheus.NewDesc(namespace+"_grpc_client_breaker_open",
+		"熔断状态 open 为 1 half_open 为 0.5", []string{"service"}, nil)
+
+	connStates = []connectivity.State{
+		connectivity.Idle, connectivity.Connecting, connectivity.Ready,
+		connectivity.TransientFailure, connectivity.Shutdown,
+	}
+)
+
+type grpcCollector struct{}
+
+func (c *grpcCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- grpcConnStateDesc
+	ch <- grpcRequestsDesc
+	ch <- grpcLatencyDesc
+	ch <- grpcBreakerDesc
+}
+
+func (c *grpcCollector) Collect(ch chan<- prometheus.Metric) {
+	for service, current := range util.GrpcConnStates() {
+		for _, state := range connStates {
+			value := 0.0
+			if state == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(grpcConnStateDesc, prometheus.GaugeValue, value, service, state.String())
+		}
+	}
+	for service, stats := range util.GrpcClientStats() {
+		for code, count := range stats.Codes {
+			ch <- prometheus.MustNewConstMetric(grpcRequestsDesc, prometheus.CounterValue, float64(count), service, code)
+		}
+		ch <- prometheus.MustNewConstMetric(grpcLatencyDesc, prometheus.CounterValue, stats.LatencyTotal.Seconds(), service)
+		breaker := 0.0
+		switch stats.Breaker {
+		case "open":
+			breaker = 1
+		case "half_open":
+			breaker = 0.5
+		}
+		ch <- prometheus.MustNewConstMetric(grpcBreakerDesc, prometheus.GaugeValue, breaker, service)
+	}
+}
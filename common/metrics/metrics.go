@@ -0,0 +1,121 @@
+package metrics
+
+/*
+ * @Desc: Prometheus 指标
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gf_user"
+
+// 认证事件结果 成功时 reason 为 ok
+const REASON_OK = "ok"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP 请求数",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP 请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	authEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_events_total",
+		Help:      "登录 注册 三方登录结果",
+	}, []string{"event", "result", "reason"})
+
+	emailSends = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "email_send_total",
+		Help:      "邮件发送结果",
+	}, []string{"result"})
+
+	wafInterruptions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "waf_interruptions_total",
+		Help:      "WAF 拦截次数",
+	}, []string{"rule_id", "action"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "gorm 语句耗时",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	dbErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "gorm 语句错误 不含记录不存在",
+	}, []string{"operation", "table"})
+
+	redisErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_errors_total",
+		Help:      "Redis 命令错误 不含 redis.Nil",
+	}, []string{"command"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, authEvents, emailSends, wafInterruptions,
+		dbDuration, dbErrors, redisErrors,
+		&grpcCollector{},
+	)
+}
+
+// Handler /metrics 处理函数
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// ObserveHttp 记录 HTTP 请求 route 为路由模板 避免按实际路径产生过多序列
+func ObserveHttp(method, route string, status int, elapsed time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// AuthEvent 记录认证事件 event 如 login register oauth_github reason 为 REASON_OK 时计为成功
+func AuthEvent(event, reason string) {
+	result := "success"
+	if reason != REASON_OK {
+		result = "failure"
+	}
+	authEvents.WithLabelValues(event, result, reason).Inc()
+}
+
+// EmailSent 记录邮件发送结果
+func EmailSent(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	emailSends.WithLabelValues(result).Inc()
+}
+
+// WafInterruption 记录 WAF 拦截
+func WafInterruption(ruleId int, action string) {
+	wafInterruptions.WithLabelValues(strconv.Itoa(ruleId), action).Inc()
+}
@@ -0,0 +1,36 @@
+package models
+
+/*
+ * @Desc: 服务实例元数据
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+// CanaryTag 带此标签的实例只接收固定了版本的调用 除非没有其他实例
+const CanaryTag = "canary"
+
+// ServiceEndpoint 注册到 etcd 的实例元数据 以 JSON 存于 /services/<name>/<addr> 的值中
+type ServiceEndpoint struct {
+	Version string   `json:"version,omitempty"` // 实例版本 用于灰度路由
+	Zone    string   `json:"zone,omitempty"`    // 可用区 优先调用同区实例
+	Weight  int      `json:"weight,omitempty"`  // 权重 默认 1
+	Tags    []string `json:"tags,omitempty"`    // 自定义标签
+}
+
+// EffectiveWeight 未配置或非法时按 1 处理
+func (e ServiceEndpoint) EffectiveWeight() int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// IsCanary 是否灰度实例
+func (e ServiceEndpoint) IsCanary() bool {
+	for _, tag := range e.Tags {
+		if tag == CanaryTag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package service
+
+/*
+ * @Desc: 配置热更新 文件监听与审计
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/fsnotify/fsnotify"
+)
+
+// 文件连续写入时合并为一次加载
+const configReloadDelay = 500 * time.Millisecond
+
+var (
+	configWatcher   *fsnotify.Watcher
+	configWatcherMu sync.Mutex
+)
+
+// WatchConfig 监听配置文件 变更后热更新 仅使用环境变量时跳过
+func WatchConfig() error {
+	path := env.ConfigFile()
+	if path == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// 监听目录 兼容编辑器替换文件与 ConfigMap 的符号链接切换
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+	configWatcherMu.Lock()
+	configWatcher = watcher
+	configWatcherMu.Unlock()
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Base(event.Name)
+				if event.Name != path && !strings.HasPrefix(name, "..") {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(configReloadDelay, func() { ReloadConfig("file") })
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warn("配置文件监听异常: ", err)
+			}
+		}
+	}()
+	log.Info("开始监听配置文件: ", path)
+	return nil
+}
+
+// StopWatchConfig 停止监听
+func StopWatchConfig() error {
+	configWatcherMu.Lock()
+	defer configWatcherMu.Unlock()
+	if configWatcher == nil {
+		return nil
+	}
+	err := configWatcher.Close()
+	configWatcher = nil
+	return err
+}
+
+// ReloadConfig 重新加载配置 每项变更写入审计日志 校验失败时保持原配置
+func ReloadConfig(source string) {
+	applied, ignored, err := env.ReloadServerConfig()
+	if err != nil {
+		log.Audit(map[string]interface{}{
+			"source": source,
+			"result": "rejected",
+			"error":  err.Error(),
+		}, "配置热更新被拒绝")
+		return
+	}
+	for _, change := range applied {
+		log.Audit(map[string]interface{}{
+			"source": source,
+			"result": "applied",
+			"field":  change.Field,
+			"old":    change.Old,
+			"new":    change.New,
+		}, "配置已更新")
+	}
+	if len(ignored) > 0 {
+		log.Warn("以下配置需重启后生效: ", strings.Join(ignored, ", "))
+	}
+	if len(applied) == 0 && len(ignored) == 0 {
+		log.Info("配置无变化")
+	}
+}
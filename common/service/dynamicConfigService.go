@@ -0,0 +1,172 @@
+package service
+
+/*
+ * @Desc: etcd 动态配置 各实例监听同一前缀 变更实时生效
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"go.etcd.io/etcd/client/v3"
+)
+
+var (
+	dynamicCancel context.CancelFunc
+	dynamicDone   chan struct{}
+	dynamicMu     sync.Mutex
+)
+
+// WatchDynamicConfig 读取并监听 etcd 动态配置 未开启时跳过
+// 首次读取在返回前完成 etcd 不可用时使用配置文件中的值 并在后台重试
+func WatchDynamicConfig() error {
+	if env.GetServerConfig().Dynamic.IsOn != "on" {
+		return nil
+	}
+	if err := initEtcdClient(); err != nil {
+		return err
+	}
+	prefix := env.DynamicPrefix()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dynamicMu.Lock()
+	dynamicCancel = cancel
+	dynamicDone = make(chan struct{})
+	done := dynamicDone
+	dynamicMu.Unlock()
+
+	values, revision, err := loadDynamicConfig(ctx, prefix)
+	if err != nil {
+		log.Warn("读取动态配置失败 暂用本地配置: ", err)
+	} else {
+		applyDynamicConfig(values)
+	}
+	go func() {
+		defer close(done)
+		watchDynamicConfig(ctx, prefix, values, revision)
+	}()
+	log.Info("开始监听动态配置: ", prefix)
+	return nil
+}
+
+// StopWatchDynamicConfig 停止监听
+func StopWatchDynamicConfig() {
+	dynamicMu.Lock()
+	cancel, done := dynamicCancel, dynamicDone
+	dynamicCancel, dynamicDone = nil, nil
+	dynamicMu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// loadDynamicConfig 读取前缀下全部键 键去掉前缀后 / 与 . 均可作为路径分隔
+func loadDynamicConfig(ctx context.Context, prefix string) (map[string]string, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := etcdClient.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询 %s 失败: %w", prefix, err)
+	}
+	values := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if key := dynamicKey(prefix, string(kv.Key)); key != "" {
+			values[key] = string(kv.Value)
+		}
+	}
+	return values, resp.Header.Revision, nil
+}
+
+// watchDynamicConfig 从 revision 之后开始监听 中断后重新全量读取
+func watchDynamicConfig(ctx context.Context, prefix string, values map[string]string, revision int64) {
+	for {
+		if revision > 0 {
+			if err := watchDynamicOnce(ctx, prefix, values, revision); err != nil {
+				log.Warn("动态配置监听异常，将在3秒后重试: ", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(3 * time.Second):
+		}
+		// 中断期间的变更与已压缩的版本通过全量读取补齐
+		next, rev, err := loadDynamicConfig(ctx, prefix)
+		if err != nil {
+			log.Warn("读取动态配置失败: ", err)
+			continue
+		}
+		values, revision = next, rev
+		applyDynamicConfig(values)
+	}
+}
+
+func watchDynamicOnce(ctx context.Context, prefix string, values map[string]string, revision int64) error {
+	watcher := etcdClient.Watch(clientv3.WithRequireLeader(ctx), prefix, clientv3.WithPrefix(), clientv3.WithRev(revision+1))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case resp, ok := <-watcher:
+			if !ok {
+				return fmt.Errorf("watch通道已关闭")
+			}
+			if resp.Err() != nil {
+				return resp.Err()
+			}
+			for _, ev := range resp.Events {
+				key := dynamicKey(prefix, string(ev.Kv.Key))
+				if key == "" {
+					continue
+				}
+				if ev.Type == clientv3.EventTypePut {
+					values[key] = string(ev.Kv.Value)
+				} else {
+					delete(values, key)
+				}
+			}
+			// 同一事务的多个键合并为一次变更
+			applyDynamicConfig(values)
+		}
+	}
+}
+
+// applyDynamicConfig 应用动态配置 每项变更写入审计日志 校验失败时保持原配置
+func applyDynamicConfig(values map[string]string) {
+	applied, skipped, err := env.ApplyDynamicConfig(values)
+	for _, item := range skipped {
+		log.Warn("动态配置已忽略 ", item)
+	}
+	if err != nil {
+		log.Audit(map[string]interface{}{
+			"source": "etcd",
+			"result": "rejected",
+			"error":  err.Error(),
+		}, "动态配置被拒绝")
+		return
+	}
+	for _, change := range applied {
+		log.Audit(map[string]interface{}{
+			"source": "etcd",
+			"result": "applied",
+			"field":  change.Field,
+			"old":    change.Old,
+			"new":    change.New,
+		}, "配置已更新")
+	}
+}
+
+// feature/register_open 与 feature.register_open 等价
+func dynamicKey(prefix string, key string) string {
+	key = strings.Trim(strings.TrimPrefix(key, prefix), "/")
+	return strings.ReplaceAll(key, "/", ".")
+}
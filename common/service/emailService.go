@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/metrics"
 	"github.com/GoFurry/gofurry-user/common/util"
 	"github.com/GoFurry/gofurry-user/roof/env"
 	"gopkg.in/gomail.v2"
@@ -80,7 +81,9 @@ func EmailSendCode(email string) (code string, gfsError common.GFError) {
 		env.GetServerConfig().Email.EmailPassword,
 	)
 
-	if err := d.DialAndSend(m); err != nil {
+	err := d.DialAndSend(m)
+	metrics.EmailSent(err)
+	if err != nil {
 		gfsError = common.NewServiceError("邮件发送失败..." + err.Error())
 	}
 	return code, gfsError
@@ -3,12 +3,15 @@ package service
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/GoFurry/gofurry-user/common/log"
+	cm "github.com/GoFurry/gofurry-user/common/models"
+	"github.com/GoFurry/gofurry-user/common/util"
 	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/bytedance/sonic"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	"go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc/resolver"
@@ -82,7 +85,7 @@ func InitEtcdOnStart() error {
 
 	// 注册解析器
 	resolver.Register(&etcdBuilder{cli: etcdClient})
-	log.Println("etcd解析器初始化成功")
+	log.Info("etcd解析器初始化成功")
 	return nil
 }
 
@@ -110,18 +113,18 @@ func (b *etcdBuilder) Scheme() string {
 // watch 监听etcd中服务地址的变化
 func (r *etcdResolver) watch() {
 	prefix := "/services/" + r.target.Endpoint() + "/"
-	log.Printf("开始监听etcd服务路径: %s", prefix)
+	log.Info("开始监听etcd服务路径: ", prefix)
 
 	// 循环监听
 	for {
 		select {
 		case <-r.ctx.Done():
-			log.Printf("解析器已关闭，停止监听: %s", prefix)
+			log.Info("解析器已关闭，停止监听: ", prefix)
 			return
 		default:
 			// 失败重试
 			if err := r.watchOnce(prefix); err != nil {
-				log.Printf("etcd监听异常，将在3秒后重试: %v", err)
+				log.Warn("etcd监听异常，将在3秒后重试: ", err)
 				time.Sleep(3 * time.Second)
 			}
 		}
@@ -158,24 +161,24 @@ func (r *etcdResolver) watchOnce(prefix string) error {
 			// 处理事件
 			addrs = r.handleEvents(resp.Events, addrs, prefix)
 			if err := r.updateClientConn(addrs); err != nil {
-				log.Printf("更新服务地址失败: %v", err) // 非致命错误，继续监听
+				log.Warn("更新服务地址失败: ", err) // 非致命错误，继续监听
 			}
 		}
 	}
 }
 
-// parseAddresses 从etcd的Kv列表中解析服务地址
+// parseAddresses 从etcd的Kv列表中解析服务地址与元数据
 func (r *etcdResolver) parseAddresses(kvs []*mvccpb.KeyValue, prefix string) []resolver.Address {
 	var addrs []resolver.Address
 	for _, kv := range kvs {
 		addr := strings.TrimPrefix(string(kv.Key), prefix)
 		if addr != "" {
-			addrs = append(addrs, resolver.Address{Addr: addr})
-			log.Printf("发现服务地址: %s", addr)
+			addrs = append(addrs, endpointAddress(addr, kv.Value))
+			log.Info("发现服务地址: ", addr, " ", string(kv.Value))
 		}
 	}
 	if len(addrs) == 0 {
-		log.Printf("未发现任何服务地址，路径: %s", prefix)
+		log.Warn("未发现任何服务地址，路径: ", prefix)
 	}
 	return addrs
 }
@@ -192,24 +195,26 @@ func (r *etcdResolver) handleEvents(events []*clientv3.Event, currentAddrs []res
 		}
 
 		if ev.Type == clientv3.EventTypePut {
-			// 新增地址
+			// 新增地址或更新元数据
+			updated := endpointAddress(addr, ev.Kv.Value)
 			exists := false
-			for _, a := range addrs {
+			for i, a := range addrs {
 				if a.Addr == addr {
+					addrs[i] = updated
 					exists = true
 					break
 				}
 			}
 			if !exists {
-				addrs = append(addrs, resolver.Address{Addr: addr})
-				log.Printf("新增服务地址: %s", addr)
+				addrs = append(addrs, updated)
+				log.Info("新增服务地址: ", addr, " ", string(ev.Kv.Value))
 			}
 		} else {
 			// 移除地址
 			for i, a := range addrs {
 				if a.Addr == addr {
 					addrs = append(addrs[:i], addrs[i+1:]...)
-					log.Printf("移除服务地址: %s", addr)
+					log.Info("移除服务地址: ", addr)
 					break
 				}
 			}
@@ -218,6 +223,17 @@ func (r *etcdResolver) handleEvents(events []*clientv3.Event, currentAddrs []res
 	return addrs
 }
 
+// endpointAddress 解析注册值中的元数据 旧版本注册的空值按默认元数据处理
+func endpointAddress(addr string, value []byte) resolver.Address {
+	var endpoint cm.ServiceEndpoint
+	if len(value) > 0 {
+		if err := sonic.Unmarshal(value, &endpoint); err != nil {
+			log.Warn("服务元数据解析失败 ", addr, ": ", err)
+		}
+	}
+	return util.WithEndpoint(resolver.Address{Addr: addr}, endpoint)
+}
+
 // updateClientConn 更新gRPC客户端的服务地址
 func (r *etcdResolver) updateClientConn(addrs []resolver.Address) error {
 	return r.cc.UpdateState(resolver.State{
@@ -227,95 +243,158 @@ func (r *etcdResolver) updateClientConn(addrs []resolver.Address) error {
 
 // ResolveNow 触发立即解析
 func (r *etcdResolver) ResolveNow(opts resolver.ResolveNowOptions) {
-	log.Printf("触发立即解析服务: %s", r.target.Endpoint())
+	log.Debug("触发立即解析服务: ", r.target.Endpoint())
 }
 
 // Close 关闭解析器
 func (r *etcdResolver) Close() {
 	r.cancel()
-	log.Printf("关闭服务解析器: %s", r.target.Endpoint())
+	log.Info("关闭服务解析器: ", r.target.Endpoint())
 }
 
 // ========================== 服务注册与注销 ==========================
 
-// RegisterToEtcd 注册服务到etcd
+const leaseTTL = 10 // 租约有效期(秒)
+
+// registration 已注册的服务 注销时先停止续约再删除
+type registration struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	lease  clientv3.LeaseID
+}
+
+var (
+	registrations = make(map[string]*registration)
+	regMu         sync.Mutex
+)
+
+// RegisterToEtcd 注册服务到etcd 值为本实例元数据 JSON 续约在注销前持续进行
 func RegisterToEtcd(serviceName, addr string) error {
 	if err := initEtcdClient(); err != nil {
 		return fmt.Errorf("注册失败：%w", err)
 	}
 
 	key := fmt.Sprintf("/services/%s/%s", serviceName, addr)
-	log.Printf("开始注册服务到etcd: %s", key)
-
-	// 创建租约 10秒过期
-	lease, err := etcdClient.Grant(context.Background(), 10)
+	value, err := sonic.MarshalString(util.LocalEndpoint())
 	if err != nil {
-		return fmt.Errorf("创建租约失败: %w", err)
+		return fmt.Errorf("序列化服务元数据失败: %w", err)
 	}
+	log.Info("开始注册服务到etcd: ", key, " ", value)
 
-	// 注册服务
-	_, err = etcdClient.Put(context.Background(), key, "", clientv3.WithLease(lease.ID))
+	regMu.Lock()
+	defer regMu.Unlock()
+	if _, ok := registrations[key]; ok {
+		return fmt.Errorf("服务已注册: %s", key)
+	}
+
+	// 首次注册限时 etcd 不可用时不阻塞启动与退出
+	putCtx, putCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	leaseID, err := putWithLease(putCtx, key, value)
+	putCancel()
 	if err != nil {
-		return fmt.Errorf("写入etcd失败: %w", err)
+		return err
 	}
 
 	// 启动续约监控
-	go keepAliveLoop(key, lease.ID)
+	ctx, cancel := context.WithCancel(context.Background())
+	reg := &registration{cancel: cancel, done: make(chan struct{}), lease: leaseID}
+	registrations[key] = reg
+	go keepAliveLoop(ctx, reg, key, value)
 	return nil
 }
 
-// keepAliveLoop 租约续约
-func keepAliveLoop(key string, leaseID clientv3.LeaseID) {
+// putWithLease 创建租约并写入注册信息
+func putWithLease(ctx context.Context, key, value string) (clientv3.LeaseID, error) {
+	lease, err := etcdClient.Grant(ctx, leaseTTL)
+	if err != nil {
+		return 0, fmt.Errorf("创建租约失败: %w", err)
+	}
+	if _, err = etcdClient.Put(ctx, key, value, clientv3.WithLease(lease.ID)); err != nil {
+		return 0, fmt.Errorf("写入etcd失败: %w", err)
+	}
+	return lease.ID, nil
+}
+
+// keepAliveLoop 租约续约 ctx 取消后退出
+func keepAliveLoop(ctx context.Context, reg *registration, key, value string) {
+	defer close(reg.done)
+	leaseID := reg.lease
 	for {
 		// 启动续约
-		keepAliveChan, err := etcdClient.KeepAlive(context.Background(), leaseID)
-		if err != nil {
-			log.Printf("续约失败，将重新注册服务: %v", err)
-			time.Sleep(2 * time.Second)
-
-			// 重新创建租约并注册
-			newLease, err := etcdClient.Grant(context.Background(), 10)
-			if err != nil {
-				log.Printf("重新创建租约失败: %v", err)
-				continue
-			}
-			leaseID = newLease.ID
-			if _, err := etcdClient.Put(context.Background(), key, "", clientv3.WithLease(leaseID)); err != nil {
-				log.Printf("重新注册服务失败: %v", err)
-				continue
+		keepAliveChan, err := etcdClient.KeepAlive(ctx, leaseID)
+		if err == nil {
+			log.Info("服务续约已启动: ", key)
+			for range keepAliveChan {
+				// 续约成功 无需处理
 			}
-			log.Printf("服务已重新注册: %s", key)
-			continue
 		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("续约中断，将重新注册服务: ", key, " ", err)
 
-		// 监听续约响应
-		log.Printf("服务续约已启动: %s", key)
-		for ka := range keepAliveChan {
-			if ka == nil {
-				log.Printf("续约通道关闭，准备重新注册: %s", key)
-				break
-			}
-			// 定期打印续约日志
-			// log.Printf("etcd续约成功: %+v", ka)
+		// 重新创建租约并注册
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
+		newLease, err := putWithLease(ctx, key, value)
+		if err != nil {
+			log.Error("重新注册服务失败: ", err)
+			continue
 		}
+		leaseID = newLease
+		regMu.Lock()
+		reg.lease = newLease
+		regMu.Unlock()
+		log.Info("服务已重新注册: ", key)
 	}
 }
 
-// UnregisterFromEtcd 从etcd注销服务
+// UnregisterFromEtcd 从etcd注销服务 停止续约并撤销租约
 func UnregisterFromEtcd(serviceName, addr string) error {
 	if err := initEtcdClient(); err != nil {
 		return fmt.Errorf("注销失败：%w", err)
 	}
 
 	key := fmt.Sprintf("/services/%s/%s", serviceName, addr)
-	_, err := etcdClient.Delete(context.Background(), key)
-	if err != nil {
+	regMu.Lock()
+	reg, ok := registrations[key]
+	delete(registrations, key)
+	regMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if ok {
+		reg.cancel()
+		<-reg.done
+		// 撤销租约 键随之删除
+		if _, err := etcdClient.Revoke(ctx, reg.lease); err == nil {
+			log.Info("服务已从etcd注销: ", key)
+			return nil
+		}
+	}
+	if _, err := etcdClient.Delete(ctx, key); err != nil {
 		return fmt.Errorf("删除etcd键失败: %w", err)
 	}
-	log.Printf("服务已从etcd注销: %s", key)
+	log.Info("服务已从etcd注销: ", key)
 	return nil
 }
 
+// PingEtcd 检查etcd连接
+func PingEtcd(ctx context.Context) error {
+	if etcdClient == nil {
+		return fmt.Errorf("etcd客户端未初始化")
+	}
+	endpoints := etcdClient.Endpoints()
+	if len(endpoints) == 0 {
+		return fmt.Errorf("etcd未配置地址")
+	}
+	_, err := etcdClient.Status(ctx, endpoints[0])
+	return err
+}
+
 // CloseEtcdClient 关闭etcd客户端
 func CloseEtcdClient() error {
 	if etcdClient != nil {
@@ -7,6 +7,7 @@ package service
  */
 
 import (
+	"net/url"
 	"time"
 
 	"github.com/GoFurry/gofurry-user/common"
@@ -17,16 +18,23 @@ import (
 	"github.com/tidwall/gjson"
 )
 
-var githubConfig = &abstract.Oauth{
-	ClientId:     env.GetServerConfig().Github.ClientId,
-	ClientSecret: env.GetServerConfig().Github.ClientSecret,
-	RedirectUrl:  env.GetServerConfig().Github.RedirectUrl,
+// 每次读取当前配置 支持热更新
+func githubConfig() *abstract.Oauth {
+	conf := env.GetServerConfig().Github
+	return &abstract.Oauth{
+		ClientId:     conf.ClientId,
+		ClientSecret: conf.ClientSecret,
+		RedirectUrl:  conf.RedirectUrl,
+	}
 }
 
-var giteeConfig = &abstract.Oauth{
-	ClientId:     env.GetServerConfig().Gitee.ClientId,
-	ClientSecret: env.GetServerConfig().Gitee.ClientSecret,
-	RedirectUrl:  env.GetServerConfig().Gitee.RedirectUrl,
+func giteeConfig() *abstract.Oauth {
+	conf := env.GetServerConfig().Gitee
+	return &abstract.Oauth{
+		ClientId:     conf.ClientId,
+		ClientSecret: conf.ClientSecret,
+		RedirectUrl:  conf.RedirectUrl,
+	}
 }
 
 // 设置请求头，明确指定语言为中文
@@ -35,14 +43,33 @@ var headersMap = map[string]string{
 	"Accept":     common.APPLICATION,
 }
 
+// Github 授权页地址
+func GetGithubAuthorizeUrl(state string) string {
+	values := url.Values{}
+	values.Set("client_id", githubConfig().ClientId)
+	values.Set("redirect_uri", githubConfig().RedirectUrl)
+	values.Set("state", state)
+	return "https://github.com/login/oauth/authorize?" + values.Encode()
+}
+
+// Gitee 授权页地址
+func GetGiteeAuthorizeUrl(state string) string {
+	values := url.Values{}
+	values.Set("client_id", giteeConfig().ClientId)
+	values.Set("redirect_uri", giteeConfig().RedirectUrl)
+	values.Set("response_type", "code")
+	values.Set("state", state)
+	return "https://gitee.com/oauth/authorize?" + values.Encode()
+}
+
 // 获取 Github accessToken
 func GetGithubToken(code string) (string, common.GFError) {
 	//请求github
 	url := "https://github.com/login/oauth/access_token"
 	// 设置参数
 	paramsMap := map[string]string{
-		"client_id":     githubConfig.ClientId,
-		"client_secret": githubConfig.ClientSecret,
+		"client_id":     githubConfig().ClientId,
+		"client_secret": githubConfig().ClientSecret,
 		"code":          code,
 	}
 
@@ -79,9 +106,9 @@ func GetGiteeToken(code string) (string, common.GFError) {
 	paramsMap := map[string]string{
 		"grant_type":    "authorization_code",
 		"code":          code,
-		"client_id":     giteeConfig.ClientId,
-		"redirect_uri":  giteeConfig.RedirectUrl,
-		"client_secret": giteeConfig.ClientSecret,
+		"client_id":     giteeConfig().ClientId,
+		"redirect_uri":  giteeConfig().RedirectUrl,
+		"client_secret": giteeConfig().ClientSecret,
 	}
 
 	// 请求
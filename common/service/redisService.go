@@ -9,7 +9,10 @@ import (
 
 	"github.com/GoFurry/gofurry-user/common"
 	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/common/metrics"
+	"github.com/GoFurry/gofurry-user/common/tracing"
 	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -37,6 +40,14 @@ func connect() {
 		DB:        0,
 		OnConnect: OnConnectFunc,
 	})
+	client.AddHook(metrics.RedisHook{})
+	// 不记录命令参数 避免令牌等写入链路
+	if err := redisotel.InstrumentTracing(client,
+		redisotel.WithTracerProvider(tracing.ChildOnlyProvider()),
+		redisotel.WithDBStatement(false),
+	); err != nil {
+		log.Error("redis链路追踪初始化失败: " + err.Error())
+	}
 	_, err := client.Ping(connCtx).Result()
 	if err != nil {
 		panic("Failed to connect to Redis:" + err.Error())
@@ -45,24 +56,50 @@ func connect() {
 
 }
 
+// PingRedis 检查 Redis 连接
+func PingRedis(pingCtx context.Context) error {
+	if client == nil {
+		return errors.New("redis客户端未初始化")
+	}
+	return client.Ping(pingCtx).Err()
+}
+
+// CloseRedis 关闭 Redis 客户端
+func CloseRedis() error {
+	if client == nil {
+		return nil
+	}
+	return client.Close()
+}
+
 func OnConnectFunc(ctx context.Context, cn *redis.Conn) error {
 	log.Debug("new redis connect...")
 	return nil
 }
 
 func Del(keys ...string) common.GFError {
-	err := client.Del(ctx, keys...).Err()
+	return DelCtx(ctx, keys...)
+}
+
+// DelCtx 携带请求上下文 纳入链路追踪
+func DelCtx(reqCtx context.Context, keys ...string) common.GFError {
+	err := client.Del(reqCtx, keys...).Err()
 	if err != nil {
-		log.Error("删除缓存失败..." + err.Error())
+		log.ErrorCtx(reqCtx, "删除缓存失败..."+err.Error())
 		return common.NewServiceError("删除缓存失败.")
 	}
 	return nil
 }
 
 func SetNX(key string, value any, expiration time.Duration) bool {
-	bool, err := client.SetNX(ctx, key, value, expiration).Result()
+	return SetNXCtx(ctx, key, value, expiration)
+}
+
+// SetNXCtx 携带请求上下文 纳入链路追踪
+func SetNXCtx(reqCtx context.Context, key string, value any, expiration time.Duration) bool {
+	bool, err := client.SetNX(reqCtx, key, value, expiration).Result()
 	if err != nil {
-		log.Error("设置缓存失败..." + err.Error())
+		log.ErrorCtx(reqCtx, "设置缓存失败..."+err.Error())
 		return false
 	}
 	return bool
@@ -74,9 +111,14 @@ func Set(key string, value any) common.GFError {
 }
 
 func SetExpire(key string, value any, expiration time.Duration) common.GFError {
-	err := client.Set(ctx, key, value, expiration).Err()
+	return SetExpireCtx(ctx, key, value, expiration)
+}
+
+// SetExpireCtx 携带请求上下文 纳入链路追踪
+func SetExpireCtx(reqCtx context.Context, key string, value any, expiration time.Duration) common.GFError {
+	err := client.Set(reqCtx, key, value, expiration).Err()
 	if err != nil {
-		log.Error("设置缓存失败..." + err.Error())
+		log.ErrorCtx(reqCtx, "设置缓存失败..."+err.Error())
 		return common.NewServiceError("设置缓存失败.")
 	}
 	return nil
@@ -88,13 +130,37 @@ func Get(key string) *redis.Cmd {
 }
 
 func GetString(key string) (data string, gfsError common.GFError) {
-	val, err := client.Get(ctx, key).Result()
+	return GetStringCtx(ctx, key)
+}
+
+// GetStringCtx 携带请求上下文 纳入链路追踪
+func GetStringCtx(reqCtx context.Context, key string) (data string, gfsError common.GFError) {
+	val, err := client.Get(reqCtx, key).Result()
 
 	switch {
 	case errors.Is(err, redis.Nil):
 		return "", nil
 	case err != nil:
-		log.Error("获取缓存失败..." + err.Error())
+		log.ErrorCtx(reqCtx, "获取缓存失败..."+err.Error())
+		return "", common.NewServiceError("获取缓存失败.")
+	}
+	return strings.TrimSpace(val), nil
+}
+
+// GetDelString 读取后立即删除 用于一次性凭证
+func GetDelString(key string) (data string, gfsError common.GFError) {
+	return GetDelStringCtx(ctx, key)
+}
+
+// GetDelStringCtx 携带请求上下文 纳入链路追踪
+func GetDelStringCtx(reqCtx context.Context, key string) (data string, gfsError common.GFError) {
+	val, err := client.GetDel(reqCtx, key).Result()
+
+	switch {
+	case errors.Is(err, redis.Nil):
+		return "", nil
+	case err != nil:
+		log.ErrorCtx(reqCtx, "获取缓存失败..."+err.Error())
 		return "", common.NewServiceError("获取缓存失败.")
 	}
 	return strings.TrimSpace(val), nil
@@ -224,15 +290,20 @@ func DelByPrefix(prefix string) common.GFError {
 
 // redis 前缀查询
 func FindByPrefix(prefix string) ([]string, common.GFError) {
+	return FindByPrefixCtx(ctx, prefix)
+}
+
+// FindByPrefixCtx 携带请求上下文 纳入链路追踪
+func FindByPrefixCtx(reqCtx context.Context, prefix string) ([]string, common.GFError) {
 	var cursor uint64 = 0
 	var resList []string
 	pattern := prefix + "*" // 匹配指定前缀的键
 
 	for {
 		// SCAN 命令，返回匹配的键和新的游标
-		keys, newCursor, err := client.Scan(ctx, cursor, pattern, 100).Result()
+		keys, newCursor, err := client.Scan(reqCtx, cursor, pattern, 100).Result()
 		if err != nil {
-			log.Error(fmt.Sprintf("redis scan err:%v", err))
+			log.ErrorCtx(reqCtx, fmt.Sprintf("redis scan err:%v", err))
 			return nil, common.NewServiceError(err.Error())
 		}
 		if len(keys) != 0 {
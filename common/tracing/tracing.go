@@ -0,0 +1,134 @@
+package tracing
+
+/*
+ * @Desc: OpenTelemetry 链路追踪
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+var (
+	provider *sdktrace.TracerProvider
+	closer   io.Closer
+)
+
+// Init 按 tracing 配置初始化 未开启时仍设置 W3C 传播器 上游的 traceparent 可透传到 gRPC
+func Init() error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	conf := env.GetServerConfig().Tracing
+	if conf.IsOn != "on" {
+		return nil
+	}
+	exporter, err := newExporter(conf)
+	if err != nil {
+		return err
+	}
+	InitWithExporter(exporter, conf.SampleRatio)
+	return nil
+}
+
+// InitWithExporter 使用指定导出器 测试中可传入 stdouttrace 或 tracetest 导出器
+func InitWithExporter(exporter sdktrace.SpanExporter, sampleRatio float64) {
+	if sampleRatio <= 0 || sampleRatio > 1 {
+		sampleRatio = 1
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(common.COMMON_PROJECT_NAME),
+		semconv.ServiceVersion(env.GetServerConfig().Server.AppVersion),
+	)
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+}
+
+// ChildOnlyProvider 只为已有父 Span 的调用创建子 Span 用于 gorm 与 Redis
+// 避免无请求上下文的语句产生大量孤立链路 调用时才取全局 Provider 可早于 Init 注册
+func ChildOnlyProvider() trace.TracerProvider {
+	return childOnlyProvider{}
+}
+
+type childOnlyProvider struct{ embedded.TracerProvider }
+
+func (p childOnlyProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return &childOnlyTracer{name: name, opts: opts}
+}
+
+type childOnlyTracer struct {
+	embedded.Tracer
+	name string
+	opts []trace.TracerOption
+}
+
+func (t *childOnlyTracer) Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, parent
+	}
+	return otel.GetTracerProvider().Tracer(t.name, t.opts...).Start(ctx, spanName, opts...)
+}
+
+// Shutdown 导出剩余 Span 并关闭导出器
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(ctx)
+	if closer != nil {
+		err = errors.Join(err, closer.Close())
+	}
+	return err
+}
+
+// TraceId 当前上下文的 trace id 无 Span 时为空
+func TraceId(ctx context.Context) string {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.HasTraceID() {
+		return ""
+	}
+	return spanCtx.TraceID().String()
+}
+
+// 创建导出器
+func newExporter(conf env.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch conf.Exporter {
+	case "", "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(conf.Endpoint)}
+		if conf.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(context.Background(), opts...)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		file, err := os.OpenFile(conf.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("打开链路追踪文件失败: %w", err)
+		}
+		closer = file
+		return stdouttrace.New(stdouttrace.WithWriter(file))
+	}
+	return nil, fmt.Errorf("不支持的链路追踪导出方式: %s", conf.Exporter)
+}
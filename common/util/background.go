@@ -0,0 +1,38 @@
+package util
+
+/*
+ * @Desc: 后台任务 退出前等待完成
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"sync"
+	"time"
+)
+
+var background sync.WaitGroup
+
+// RunAsync 在后台执行不影响响应的任务 如回写使用时间 程序退出时会等待其完成
+func RunAsync(fn func()) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		fn()
+	}()
+}
+
+// WaitBackground 等待后台任务完成 超时返回 false
+func WaitBackground(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,68 @@
+package util
+
+/*
+ * @Desc: 登录 Cookie
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"strings"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/gofiber/fiber/v2"
+)
+
+// 登录 Cookie 名称
+func AuthCookieName() string {
+	if name := env.GetServerConfig().Auth.Cookie.Name; name != "" {
+		return name
+	}
+	return "Authorization"
+}
+
+// NewAuthCookie 按配置生成登录 Cookie
+func NewAuthCookie(token string) *fiber.Cookie {
+	conf := env.GetServerConfig().Auth.Cookie
+
+	path := conf.Path
+	if path == "" {
+		path = "/" // 全站有效
+	}
+	maxAge := time.Duration(conf.MaxAge) * time.Second
+	if conf.MaxAge <= 0 {
+		maxAge = common.JWT_RELET_NUM * time.Hour // 与 JWT 有效期一致
+	}
+	var sameSite string
+	switch strings.ToLower(conf.SameSite) {
+	case "strict":
+		sameSite = fiber.CookieSameSiteStrictMode
+	case "none":
+		sameSite = fiber.CookieSameSiteNoneMode
+	default:
+		sameSite = fiber.CookieSameSiteLaxMode
+	}
+
+	return &fiber.Cookie{
+		Name:     AuthCookieName(),
+		Value:    token,
+		Expires:  time.Now().Add(maxAge),
+		MaxAge:   int(maxAge.Seconds()),
+		Path:     path,
+		Domain:   conf.Domain,
+		Secure:   conf.Secure || sameSite == fiber.CookieSameSiteNoneMode, // SameSite=None 必须 Secure
+		HTTPOnly: true,
+		SameSite: sameSite,
+	}
+}
+
+// GetAuthToken 读取登录凭证 优先请求头 其次 Cookie
+func GetAuthToken(c *fiber.Ctx) string {
+	token := strings.TrimSpace(c.Get("Authorization"))
+	if token == "" {
+		token = strings.TrimSpace(c.Cookies(AuthCookieName()))
+	}
+	return token
+}
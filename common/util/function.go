@@ -8,7 +8,9 @@ package util
 
 import (
 	"crypto/md5"
+	crand "crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
@@ -53,6 +55,12 @@ func CreateMD5(str string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// SHA256 摘要
+func CreateSHA256(str string) string {
+	sum := sha256.Sum256([]byte(str))
+	return hex.EncodeToString(sum[:])
+}
+
 // 判断是否为数字
 func IsNumber(str string) bool {
 	_, err := strconv.Atoi(str)
@@ -173,6 +181,13 @@ func GenerateRandomCode(length int) (code string) {
 	return
 }
 
+// 生成安全随机串 用于 state 等防伪参数
+func GenerateSecureToken(byteLen int) string {
+	b := make([]byte, byteLen)
+	_, _ = crand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
 // JWT 密钥
 func Secret() jwt.Keyfunc {
 	return func(token *jwt.Token) (interface{}, error) {
@@ -183,9 +198,6 @@ func Secret() jwt.Keyfunc {
 // 解密JWT Token
 func ParseToken(authorization string) (*cm.GFClaims, error) {
 	token, err := jwt.ParseWithClaims(authorization, &cm.GFClaims{}, Secret())
-	if err != nil {
-		fmt.Println(err)
-	}
 	if claims, ok := token.Claims.(*cm.GFClaims); ok && token.Valid {
 		return claims, nil
 	}
@@ -278,26 +290,11 @@ func MergeAndDeduplicate(arr1, arr2 []int64) []int64 {
 }
 
 func DecryptPassword(encryptedPassword string, privateKeyPath string) (string, error) {
-	// 读取私钥文件
-	privKeyData, err := os.ReadFile(privateKeyPath)
-	if err != nil {
-		return "", err
-	}
-	// 解码 PEM 格式的私钥
-	block, _ := pem.Decode(privKeyData)
-	if block == nil {
-		return "", errors.New("failed to parse PEM block containing the private key")
-	}
-	// 解析私钥
-	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	// 读取私钥
+	rsaPrivKey, err := LoadRSAPrivateKey(privateKeyPath)
 	if err != nil {
 		return "", err
 	}
-	// 将 privKey 转换为 *rsa.PrivateKey
-	rsaPrivKey, ok := privKey.(*rsa.PrivateKey)
-	if !ok {
-		return "", errors.New("private key is not of type *rsa.PrivateKey")
-	}
 
 	// 解码 Base64 密文
 	encryptedData, err := base64.StdEncoding.DecodeString(encryptedPassword)
@@ -314,6 +311,27 @@ func DecryptPassword(encryptedPassword string, privateKeyPath string) (string, e
 	return string(decrypted), nil
 }
 
+// 读取 PKCS8 PEM 格式的 RSA 私钥
+func LoadRSAPrivateKey(privateKeyPath string) (*rsa.PrivateKey, error) {
+	privKeyData, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(privKeyData)
+	if block == nil {
+		return nil, errors.New("failed to parse PEM block containing the private key")
+	}
+	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaPrivKey, ok := privKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not of type *rsa.PrivateKey")
+	}
+	return rsaPrivKey, nil
+}
+
 func FileExists(path string) bool {
 	_, err := os.Stat(path)
 	return !os.IsNotExist(err) // 不存在返回 false，存在返回 true
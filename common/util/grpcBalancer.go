@@ -0,0 +1,124 @@
+package util
+
+/*
+ * @Desc: gRPC 负载均衡 同区优先 按权重 灰度版本
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"math/rand"
+
+	cm "github.com/GoFurry/gofurry-user/common/models"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+)
+
+// WeightedBalancerName 负载均衡策略名 在 service config 中引用
+const WeightedBalancerName = "gf_weighted"
+
+type endpointKey struct{}
+type versionKey struct{}
+
+func init() {
+	balancer.Register(base.NewBalancerBuilder(WeightedBalancerName, &weightedPickerBuilder{}, base.Config{HealthCheck: true}))
+}
+
+// LocalEndpoint 本实例的注册元数据
+func LocalEndpoint() cm.ServiceEndpoint {
+	conf := env.GetServerConfig().Endpoint
+	version := conf.Version
+	if version == "" {
+		version = env.GetServerConfig().Server.AppVersion
+	}
+	return cm.ServiceEndpoint{
+		Version: version,
+		Zone:    conf.Zone,
+		Weight:  conf.Weight,
+		Tags:    conf.Tags,
+	}
+}
+
+// WithEndpoint 将实例元数据写入解析器地址属性
+func WithEndpoint(addr resolver.Address, endpoint cm.ServiceEndpoint) resolver.Address {
+	addr.Attributes = addr.Attributes.WithValue(endpointKey{}, endpoint)
+	return addr
+}
+
+// EndpointOf 读取地址上的实例元数据 未携带时返回零值
+func EndpointOf(addr resolver.Address) cm.ServiceEndpoint {
+	endpoint, _ := addr.Attributes.Value(endpointKey{}).(cm.ServiceEndpoint)
+	return endpoint
+}
+
+// WithGrpcVersion 本次调用固定到指定版本的实例 优先于 grpc_clients.version
+func WithGrpcVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+type weightedPickerBuilder struct{}
+
+// Build 就绪连接变化时重建 picker 调用时再按版本与可用区筛选
+func (b *weightedPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	picker := &weightedPicker{zone: env.GetServerConfig().Endpoint.Zone}
+	for sc, scInfo := range info.ReadySCs {
+		picker.subConns = append(picker.subConns, weightedSubConn{sc: sc, endpoint: EndpointOf(scInfo.Address)})
+	}
+	return picker
+}
+
+type weightedSubConn struct {
+	sc       balancer.SubConn
+	endpoint cm.ServiceEndpoint
+}
+
+type weightedPicker struct {
+	zone     string
+	subConns []weightedSubConn
+}
+
+// Pick 依次按版本(未固定时排除灰度实例) 可用区筛选 筛选结果为空时不收窄 最后按权重随机
+func (p *weightedPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	candidates := p.subConns
+	if version, _ := info.Ctx.Value(versionKey{}).(string); version != "" {
+		candidates = narrow(candidates, func(s weightedSubConn) bool { return s.endpoint.Version == version })
+	} else {
+		candidates = narrow(candidates, func(s weightedSubConn) bool { return !s.endpoint.IsCanary() })
+	}
+	if p.zone != "" {
+		candidates = narrow(candidates, func(s weightedSubConn) bool { return s.endpoint.Zone == p.zone })
+	}
+
+	total := 0
+	for _, s := range candidates {
+		total += s.endpoint.EffectiveWeight()
+	}
+	n := rand.Intn(total)
+	for _, s := range candidates {
+		n -= s.endpoint.EffectiveWeight()
+		if n < 0 {
+			return balancer.PickResult{SubConn: s.sc}, nil
+		}
+	}
+	return balancer.PickResult{SubConn: candidates[len(candidates)-1].sc}, nil
+}
+
+// 过滤实例 无匹配时保留原列表
+func narrow(subConns []weightedSubConn, match func(weightedSubConn) bool) []weightedSubConn {
+	var matched []weightedSubConn
+	for _, s := range subConns {
+		if match(s) {
+			matched = append(matched, s)
+		}
+	}
+	if len(matched) == 0 {
+		return subConns
+	}
+	return matched
+}
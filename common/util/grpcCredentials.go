@@ -0,0 +1,59 @@
+package util
+
+/*
+ * @Desc: gRPC 客户端证书
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// LoadGrpcCredentials 按服务名加载传输凭证
+// 未单独配置的服务使用 key.grpc_tls 作为 CA 两者都未配置时返回错误 明文须在 grpc_clients 中声明 insecure: true
+func LoadGrpcCredentials(serviceName string) (credentials.TransportCredentials, error) {
+	conf, ok := env.GetServerConfig().GrpcClients[serviceName]
+	if !ok {
+		caFile := env.GetServerConfig().Key.GrpcTls
+		if caFile == "" {
+			return nil, fmt.Errorf("服务 %s 未配置传输凭证 请配置 grpc_clients.%s 或 key.grpc_tls", serviceName, serviceName)
+		}
+		conf = env.GrpcClientConfig{CaFile: caFile}
+	}
+	if conf.Insecure {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConf := &tls.Config{
+		ServerName: conf.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if conf.CaFile != "" {
+		caPem, err := os.ReadFile(conf.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("解析CA证书失败: %s", conf.CaFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+	// 双向 TLS
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConf), nil
+}
@@ -0,0 +1,70 @@
+package util
+
+/*
+ * @Desc: gRPC 服务发现 按服务选择 etcd static dns
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"fmt"
+
+	"github.com/GoFurry/gofurry-user/common"
+	cm "github.com/GoFurry/gofurry-user/common/models"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"google.golang.org/grpc/resolver"
+)
+
+const staticScheme = "static"
+
+func init() {
+	resolver.Register(&staticBuilder{})
+}
+
+// grpcTarget 按 grpc_clients.<服务名>.discovery 生成拨号地址
+func grpcTarget(serviceName string) (string, error) {
+	conf := env.GetServerConfig().GrpcClients[serviceName]
+	switch conf.Discovery {
+	case "", common.DISCOVERY_ETCD:
+		// etcd 解析器在 InitEtcdOnStart 成功后才会注册
+		if resolver.Get("etcd") == nil {
+			return "", fmt.Errorf("etcd解析器未初始化 无法发现服务: %s", serviceName)
+		}
+		return "etcd:///" + serviceName, nil
+	case common.DISCOVERY_STATIC:
+		if len(conf.Addresses) == 0 {
+			return "", fmt.Errorf("服务 %s 未配置静态地址", serviceName)
+		}
+		return staticScheme + ":///" + serviceName, nil
+	case common.DISCOVERY_DNS:
+		if conf.DnsTarget == "" {
+			return "", fmt.Errorf("服务 %s 未配置DNS地址", serviceName)
+		}
+		return "dns:///" + conf.DnsTarget, nil
+	}
+	return "", fmt.Errorf("服务 %s 的发现方式不支持: %s", serviceName, conf.Discovery)
+}
+
+// staticBuilder 从配置文件读取固定地址 用于本地开发与测试
+type staticBuilder struct{}
+
+func (b *staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	var addrs []resolver.Address
+	for _, addr := range env.GetServerConfig().GrpcClients[target.Endpoint()].Addresses {
+		addrs = append(addrs, WithEndpoint(resolver.Address{Addr: addr}, cm.ServiceEndpoint{}))
+	}
+	// 地址不可用时由负载均衡重连 此处无需处理
+	_ = cc.UpdateState(resolver.State{Addresses: addrs})
+	return &staticResolver{}, nil
+}
+
+func (b *staticBuilder) Scheme() string {
+	return staticScheme
+}
+
+// staticResolver 地址固定 无需重新解析
+type staticResolver struct{}
+
+func (r *staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *staticResolver) Close() {}
@@ -0,0 +1,278 @@
+package util
+
+/*
+ * @Desc: gRPC 客户端拦截器 超时 重试 熔断 统计 版本固定
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/bytedance/sonic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultGrpcTimeout     = 5 * time.Second
+	defaultRetryAttempts   = 3
+	defaultInitialBackoff  = 100 * time.Millisecond
+	defaultMaxBackoff      = time.Second
+	defaultBreakerFailures = 5
+	defaultBreakerOpen     = 30 * time.Second
+)
+
+// grpcServiceConfig 负载均衡与幂等方法的重试策略
+func grpcServiceConfig(conf env.GrpcClientConfig) string {
+	serviceConfig := map[string]any{"loadBalancingPolicy": WeightedBalancerName}
+
+	var names []map[string]string
+	for _, method := range conf.Retry.Methods {
+		service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+		if !ok || service == "" {
+			continue
+		}
+		names = append(names, map[string]string{"service": service, "method": name})
+	}
+	if len(names) > 0 {
+		attempts := conf.Retry.MaxAttempts
+		if attempts <= 1 {
+			attempts = defaultRetryAttempts
+		}
+		initial := msOrDefault(conf.Retry.InitialBackoff, defaultInitialBackoff)
+		maxBackoff := msOrDefault(conf.Retry.MaxBackoff, defaultMaxBackoff)
+		serviceConfig["methodConfig"] = []map[string]any{{
+			"name": names,
+			"retryPolicy": map[string]any{
+				"maxAttempts":          attempts,
+				"initialBackoff":       fmt.Sprintf("%.3fs", initial.Seconds()),
+				"maxBackoff":           fmt.Sprintf("%.3fs", maxBackoff.Seconds()),
+				"backoffMultiplier":    2,
+				"retryableStatusCodes": []string{"UNAVAILABLE"},
+			},
+		}}
+	}
+	data, _ := sonic.MarshalString(serviceConfig)
+	return data
+}
+
+// timeoutInterceptor 按方法设置调用超时 调用方已有更短的截止时间时保留
+func timeoutInterceptor(conf env.GrpcClientConfig) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		timeout := msOrDefault(conf.Timeout, defaultGrpcTimeout)
+		if ms, ok := conf.MethodTimeouts[method]; ok && ms > 0 {
+			timeout = time.Duration(ms) * time.Millisecond
+		} else if ms, ok = conf.MethodTimeouts[method[strings.LastIndex(method, "/")+1:]]; ok && ms > 0 {
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// versionInterceptor 按 grpc_clients.version 固定实例版本 调用方已指定时不覆盖
+func versionInterceptor(version string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Value(versionKey{}).(string); !ok {
+			ctx = WithGrpcVersion(ctx, version)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// ========================== 熔断 ==========================
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker 连续失败达到阈值后熔断 到期后放行单个探测请求
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openUntil time.Time
+	probing   bool
+	threshold int
+	openFor   time.Duration
+}
+
+func newCircuitBreaker(conf env.GrpcBreakerConfig) *circuitBreaker {
+	threshold := conf.Threshold
+	if threshold <= 0 {
+		threshold = defaultBreakerFailures
+	}
+	openFor := defaultBreakerOpen
+	if conf.OpenSeconds > 0 {
+		openFor = time.Duration(conf.OpenSeconds) * time.Second
+	}
+	return &circuitBreaker{threshold: threshold, openFor: openFor}
+}
+
+// allow 是否放行 半开状态仅放行一个探测请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Now().Before(b.openUntil) {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record 记录调用结果
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		b.state = breakerClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openUntil = time.Now().Add(b.openFor)
+		b.probing = false
+	}
+}
+
+// 当前状态 供统计使用
+func (b *circuitBreaker) stateName() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+// 仅服务端故障计入熔断 业务错误不计入
+func isBreakerFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+func breakerInterceptor(breaker *circuitBreaker) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !breaker.allow() {
+			return status.Error(codes.Unavailable, "服务熔断中: "+cc.Target())
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		breaker.record(!isBreakerFailure(err))
+		return err
+	}
+}
+
+// ========================== 统计 ==========================
+
+// GrpcTargetStats 单个服务的调用统计
+type GrpcTargetStats struct {
+	Requests     uint64            `json:"requests"`     // 调用次数
+	Failures     uint64            `json:"failures"`     // 失败次数
+	Codes        map[string]uint64 `json:"codes"`        // 按状态码计数
+	LatencyTotal time.Duration     `json:"latencyTotal"` // 累计耗时
+	LatencyMax   time.Duration     `json:"latencyMax"`   // 最大耗时
+	Breaker      string            `json:"breaker"`      // 熔断状态
+}
+
+var (
+	grpcStats    = make(map[string]*GrpcTargetStats)
+	grpcBreakers = make(map[string]*circuitBreaker)
+	statsMu      sync.Mutex
+)
+
+func metricsInterceptor(serviceName string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		elapsed := time.Since(start)
+
+		statsMu.Lock()
+		stats, ok := grpcStats[serviceName]
+		if !ok {
+			stats = &GrpcTargetStats{Codes: make(map[string]uint64)}
+			grpcStats[serviceName] = stats
+		}
+		stats.Requests++
+		if err != nil {
+			stats.Failures++
+		}
+		stats.Codes[status.Code(err).String()]++
+		stats.LatencyTotal += elapsed
+		if elapsed > stats.LatencyMax {
+			stats.LatencyMax = elapsed
+		}
+		statsMu.Unlock()
+		return err
+	}
+}
+
+// GrpcClientStats 各服务调用统计快照
+func GrpcClientStats() map[string]GrpcTargetStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	snapshot := make(map[string]GrpcTargetStats, len(grpcStats))
+	for name, stats := range grpcStats {
+		item := *stats
+		item.Codes = make(map[string]uint64, len(stats.Codes))
+		for code, count := range stats.Codes {
+			item.Codes[code] = count
+		}
+		if breaker, ok := grpcBreakers[name]; ok {
+			item.Breaker = breaker.stateName()
+		}
+		snapshot[name] = item
+	}
+	return snapshot
+}
+
+// 获取服务的熔断器 同一服务重建连接时沿用
+func breakerFor(serviceName string, conf env.GrpcBreakerConfig) *circuitBreaker {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	breaker, ok := grpcBreakers[serviceName]
+	if !ok {
+		breaker = newCircuitBreaker(conf)
+		grpcBreakers[serviceName] = breaker
+	}
+	return breaker
+}
+
+// 毫秒配置转时长
+func msOrDefault(ms int, def time.Duration) time.Duration {
+	if ms <= 0 {
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
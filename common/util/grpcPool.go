@@ -4,10 +4,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 /*
@@ -18,6 +18,7 @@ import (
 
 var (
 	grpcConns           = make(map[string]*grpc.ClientConn)
+	grpcDialOpts        = make(map[string][]grpc.DialOption) // 各服务首次建连时的拨号参数 重建连接时复用
 	mu                  sync.RWMutex
 	healthCheckInterval = 5 * time.Minute // 健康检查间隔
 )
@@ -27,8 +28,8 @@ func init() {
 	go startHealthCheck()
 }
 
-// GetGrpcClientConn 获取指定服务的 gRPC 连接
-func GetGrpcClientConn(serviceName string, creds *credentials.TransportCredentials) (newConn *grpc.ClientConn, err error) {
+// GetGrpcClientConn 获取指定服务的 gRPC 连接 发现方式与传输凭证按 grpc_clients 配置加载
+func GetGrpcClientConn(serviceName string) (newConn *grpc.ClientConn, err error) {
 	// 先尝试读锁获取已有连接
 	mu.RLock()
 	conn, exists := grpcConns[serviceName]
@@ -49,30 +50,82 @@ func GetGrpcClientConn(serviceName string, creds *credentials.TransportCredentia
 	}
 
 	// 创建新连接
-	target := "etcd:///" + serviceName
-	if creds == nil {
-		newConn, err = grpc.NewClient(
-			target,
-			grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy":"round_robin"}`),
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-		)
-	} else {
-		newConn, err = grpc.NewClient(
-			target,
-			grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy":"round_robin"}`),
-			grpc.WithTransportCredentials(*creds),
-		)
+	target, err := grpcTarget(serviceName)
+	if err != nil {
+		return nil, err
 	}
-
+	opts, err := dialOptions(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	newConn, err = grpc.NewClient(target, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	// 关闭不可用的旧连接
+	if exists {
+		_ = conn.Close()
+	}
 	// 存入连接池
 	grpcConns[serviceName] = newConn
 	return newConn, nil
 }
 
+// dialOptions 获取服务的拨号参数 首次调用时加载并缓存 调用方需持有写锁
+func dialOptions(serviceName string) ([]grpc.DialOption, error) {
+	if opts, ok := grpcDialOpts[serviceName]; ok {
+		return opts, nil
+	}
+	creds, err := LoadGrpcCredentials(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	conf := env.GetServerConfig().GrpcClients[serviceName]
+	// 统计在最外层 熔断拒绝的请求同样计入
+	interceptors := []grpc.UnaryClientInterceptor{
+		metricsInterceptor(serviceName),
+		breakerInterceptor(breakerFor(serviceName, conf.Breaker)),
+		timeoutInterceptor(conf),
+	}
+	if conf.Version != "" {
+		interceptors = append(interceptors, versionInterceptor(conf.Version))
+	}
+	opts := []grpc.DialOption{
+		grpc.WithDefaultServiceConfig(grpcServiceConfig(conf)),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(interceptors...),
+		// 链路追踪 traceparent 写入 gRPC metadata
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}
+	grpcDialOpts[serviceName] = opts
+	return opts, nil
+}
+
+// GrpcConnState 获取服务连接状态 空闲连接会被唤醒
+func GrpcConnState(serviceName string) (connectivity.State, error) {
+	conn, err := GetGrpcClientConn(serviceName)
+	if err != nil {
+		return connectivity.Shutdown, err
+	}
+	state := conn.GetState()
+	if state == connectivity.Idle {
+		conn.Connect()
+	}
+	return state, nil
+}
+
+// GrpcConnStates 连接池中各服务的连接状态 不会新建连接
+func GrpcConnStates() map[string]connectivity.State {
+	mu.RLock()
+	defer mu.RUnlock()
+	states := make(map[string]connectivity.State, len(grpcConns))
+	for name, conn := range grpcConns {
+		states[name] = conn.GetState()
+	}
+	return states
+}
+
 // CloseGrpcConns 程序退出时关闭所有 gRPC 连接
 func CloseGrpcConns() {
 	mu.Lock()
@@ -81,6 +134,7 @@ func CloseGrpcConns() {
 		_ = conn.Close()
 	}
 	grpcConns = make(map[string]*grpc.ClientConn)
+	grpcDialOpts = make(map[string][]grpc.DialOption)
 }
 
 // 启动健康检查协程
@@ -142,13 +196,16 @@ func rebuildConnection(serviceName string) error {
 		return nil
 	}
 
-	// 创建新连接
-	target := "etcd:///" + serviceName
-	newConn, err := grpc.NewClient(
-		target,
-		grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy":"round_robin"}`),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	// 沿用首次建连的拨号参数 不会降级为明文
+	target, err := grpcTarget(serviceName)
+	if err != nil {
+		return err
+	}
+	opts, err := dialOptions(serviceName)
+	if err != nil {
+		return err
+	}
+	newConn, err := grpc.NewClient(target, opts...)
 	if err != nil {
 		return err
 	}
@@ -1,19 +1,30 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
+	hs "github.com/GoFurry/gofurry-user/apps/util/health/service"
 	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/log"
 	cs "github.com/GoFurry/gofurry-user/common/service"
+	"github.com/GoFurry/gofurry-user/common/tracing"
 	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/GoFurry/gofurry-user/roof/db"
 	"github.com/GoFurry/gofurry-user/roof/env"
 	routers "github.com/GoFurry/gofurry-user/router"
-	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2"
 	"github.com/kardianos/service"
+	"google.golang.org/grpc"
 )
 
 //@title GoFurry-User
@@ -21,7 +32,8 @@ import (
 //@description GoFurry-User
 
 var (
-	errChan = make(chan error)
+	// 服务启动失败时写入 触发退出流程
+	errChan = make(chan error, 2)
 )
 
 func main() {
@@ -43,8 +55,8 @@ WorkingDirectory=` + dir + `/
 ExecStart=` + dir + `/gf-user
 Restart=always
 RestartSec=30
-LogOutput=true
-LogDirectory=/var/log/gf-user
+ExecReload=/bin/kill -HUP $MAINPID
+LogDirectory=gf-user
 LimitNOFILE=65535
 
 [Install]
@@ -52,6 +64,8 @@ WantedBy=multi-user.target`,
 		},
 	}
 	prg := &goFurry{}
+	// 收到退出信号或服务启动失败后由 service 调用 Stop
+	svcConfig.Option["RunWait"] = prg.wait
 	s, err := service.New(prg, svcConfig)
 	if err != nil {
 		log.Error(err)
@@ -82,8 +96,45 @@ WantedBy=multi-user.target`,
 			log.Info("gf-user V1.0.0")
 			return
 		}
+
+		// 加密敏感配置 明文从标准输入读取 避免留在命令历史
+		if os.Args[1] == "encrypt-secret" {
+			plain, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				log.Error("读取输入失败: ", err)
+				os.Exit(1)
+			}
+			value, err := env.EncryptSecret(strings.TrimRight(string(plain), "\r\n"))
+			if err != nil {
+				log.Error("加密失败: ", err)
+				os.Exit(1)
+			}
+			fmt.Println(value)
+			return
+		}
+
+		// 运维命令 执行后退出
+		if isCliCommand(os.Args[1]) {
+			os.Exit(runCli(os.Args[1:]))
+		}
+
+		// 部署流水线中校验配置 不启动服务 不连接外部依赖
+		if os.Args[1] == "check-config" {
+			if !checkConfig() {
+				os.Exit(1)
+			}
+			log.Info("配置检查通过")
+			return
+		}
 	}
 
+	// 配置有误时拒绝启动
+	if !checkConfig() {
+		os.Exit(1)
+	}
+	// 敏感字段已脱敏
+	log.Debug("生效配置:\n", env.GetServerConfig())
+
 	// 内存限制和 GC 策略
 	debug.SetGCPercent(1000)
 	debug.SetMemoryLimit(int64(env.GetServerConfig().Server.MemoryLimit << 30))
@@ -97,34 +148,105 @@ WantedBy=multi-user.target`,
 	}
 }
 
-type goFurry struct{}
+type goFurry struct {
+	app        *fiber.App
+	metricsApp *fiber.App
+	grpcServer *grpc.Server // 由 regMu 保护 启动协程写入 Stop 读取
+	stopping   bool         // 已开始退出 尚未启动的 gRPC 服务不再启动
+	regMu      sync.Mutex
+	registered [][2]string // 已注册到 etcd 的服务名与地址
+}
 
 func InitOnStart() {
-	// 初始化 etcd
-	if err := cs.InitEtcdOnStart(); err != nil {
+	// 初始化链路追踪 导出器不可用时不影响服务启动
+	if err := tracing.Init(); err != nil {
+		log.Error("链路追踪初始化失败: ", err)
+	}
+	// 初始化 etcd 不可用时仅影响 etcd 发现的服务 static dns 方式不依赖 etcd
+	if env.GetServerConfig().Etcd.EtcdHost == "" {
+		log.Warn("未配置etcd 跳过etcd解析器初始化")
+	} else if err := cs.InitEtcdOnStart(); err != nil {
 		log.Error(err)
-		os.Exit(0)
+	}
+	// etcd 动态配置 叠加在配置文件之上
+	if err := cs.WatchDynamicConfig(); err != nil {
+		log.Error("动态配置监听失败: ", err)
 	}
 	// 初始化 redis
 	cs.InitRedisOnStart()
+	// 监听配置文件 热更新
+	if err := cs.WatchConfig(); err != nil {
+		log.Error("配置文件监听失败: ", err)
+	}
+	// 检查数据库连接 数据库晚于服务启动时按退避间隔重试
+	if err := db.Orm.Connect(context.Background()); err != nil {
+		log.Fatal("数据库连接失败: ", err)
+	}
+	// 数据库迁移 多实例同时启动时依次执行
+	if err := db.Orm.MigrateOnStart(context.Background()); err != nil {
+		log.Fatal("数据库迁移失败: ", err)
+	}
+}
+
+// checkConfig 校验配置 逐条输出问题
+func checkConfig() bool {
+	err := env.Validate()
+	if err == nil {
+		return true
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, item := range joined.Unwrap() {
+			log.Error("配置错误 ", item)
+		}
+	} else {
+		log.Error("配置错误 ", err)
+	}
+	return false
 }
 
 func (gf *goFurry) Start(s service.Service) error {
-	go gf.run()
+	// run 不阻塞 监听在各自协程中进行
+	gf.run()
 	return nil
 }
 
+// wait 阻塞至收到退出信号或服务启动失败 SIGHUP 时重新打开日志文件并热更新配置
+func (gf *goFurry) wait() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(c)
+	for {
+		select {
+		case sig := <-c:
+			if sig == syscall.SIGHUP {
+				if err := log.Reopen(); err != nil {
+					log.Error("日志文件重新打开失败: ", err)
+				} else {
+					log.Info("日志文件已重新打开")
+				}
+				cs.ReloadConfig("SIGHUP")
+				continue
+			}
+			log.Info("收到退出信号: ", sig)
+		case err := <-errChan:
+			log.Error("服务异常退出: ", err)
+		}
+		return
+	}
+}
+
 func (gf *goFurry) run() {
-	go func() {
-		c := make(chan os.Signal)
-		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-		errChan <- fmt.Errorf("%s", <-c)
-	}()
 	// 启动 web
+	app := routers.Router.Init()
+	// 开始监听后再注册 避免流量先于端口就绪
+	app.Hooks().OnListen(func(fiber.ListenData) error {
+		gf.register(routers.Router.ServiceName(), routers.Router.AdvertiseAddr())
+		hs.GetHealthService().SetState(hs.STATE_READY)
+		return nil
+	})
+	gf.app = app
 	go func() {
-		app := routers.Router.Init()
-
-		addr := env.GetServerConfig().Server.IPAddress + ":" + env.GetServerConfig().Server.Port
+		addr := routers.Router.ListenAddr()
 		// nginx 完成 https 就不使用 TLS
 		//pem := env.GetServerConfig().Key.TlsPem
 		//key := env.GetServerConfig().Key.TlsKey
@@ -133,23 +255,183 @@ func (gf *goFurry) run() {
 		//	errChan <- err
 		//}
 		if err := app.Listen(addr); err != nil {
-			fmt.Println(err)
 			errChan <- err
 		}
 	}()
-	if err := <-errChan; err != nil {
-		log.Error(err)
+	// 指标 独立端口
+	if env.GetServerConfig().Middleware.Metrics.IsOn == "on" {
+		gf.metricsApp = routers.Router.MetricsApp()
+		go func(app *fiber.App) {
+			if err := app.Listen(routers.Router.MetricsListenAddr()); err != nil {
+				errChan <- err
+			}
+		}(gf.metricsApp)
+	}
+	// 启动 gRPC
+	if env.GetServerConfig().GrpcServer.IsOn == "on" {
+		go gf.serveGrpc()
+	}
+}
+
+// serveGrpc 启动 gRPC 服务 监听成功后注册到 etcd
+func (gf *goFurry) serveGrpc() {
+	server, err := routers.GrpcServer.Init()
+	if err != nil {
+		log.Error("gRPC服务初始化失败: ", err)
+		errChan <- err
+		return
+	}
+	lis, err := net.Listen("tcp", routers.GrpcServer.ListenAddr())
+	if err != nil {
+		log.Error("gRPC端口监听失败: ", err)
+		errChan <- err
+		return
+	}
+	gf.regMu.Lock()
+	if gf.stopping {
+		gf.regMu.Unlock()
+		_ = lis.Close()
+		return
+	}
+	gf.grpcServer = server
+	gf.regMu.Unlock()
+	gf.register(routers.GrpcServer.ServiceName(), routers.GrpcServer.AdvertiseAddr())
+	if err = server.Serve(lis); err != nil {
+		errChan <- err
+	}
+}
+
+// register 注册到 etcd 未配置 etcd 时跳过
+func (gf *goFurry) register(serviceName, addr string) {
+	if env.GetServerConfig().Etcd.EtcdHost == "" {
+		return
+	}
+	// 注册期间持有锁 退出流程等待注册完成后再注销
+	gf.regMu.Lock()
+	defer gf.regMu.Unlock()
+	if gf.stopping {
+		return
+	}
+	if err := cs.RegisterToEtcd(serviceName, addr); err != nil {
+		log.Error("服务注册失败: ", serviceName, " ", err)
+		return
+	}
+	gf.registered = append(gf.registered, [2]string{serviceName, addr})
+}
+
+// deregister 注销所有已注册的服务
+func (gf *goFurry) deregister() {
+	gf.regMu.Lock()
+	registered := gf.registered
+	gf.registered = nil
+	gf.regMu.Unlock()
+	for _, item := range registered {
+		if err := cs.UnregisterFromEtcd(item[0], item[1]); err != nil {
+			log.Error("服务注销失败: ", item[0], " ", err)
+		}
 	}
 }
 
 func (gf *goFurry) Stop(s service.Service) error {
+	timeout := shutdownTimeout()
+	deadline := time.Now().Add(timeout)
+	log.Info("开始退出 最长等待: ", timeout)
+	hs.GetHealthService().SetState(hs.STATE_STOPPING)
+	// 启动中的服务不再注册与监听
+	gf.regMu.Lock()
+	gf.stopping = true
+	gf.regMu.Unlock()
+
+	_ = cs.StopWatchConfig()
+	cs.StopWatchDynamicConfig()
+	// 先注销 负载均衡不再转发新流量后再停止服务
+	gf.deregister()
+	log.Info("服务已注销")
+
+	// 停止接收新请求 等待处理中的请求完成
+	if gf.app != nil {
+		if err := gf.app.ShutdownWithTimeout(time.Until(deadline)); err != nil {
+			log.Error("HTTP服务关闭失败: ", err)
+		} else {
+			log.Info("HTTP服务已关闭")
+		}
+	}
+	if gf.metricsApp != nil {
+		_ = gf.metricsApp.ShutdownWithTimeout(time.Until(deadline))
+	}
+	gf.regMu.Lock()
+	grpcServer := gf.grpcServer
+	gf.regMu.Unlock()
+	if grpcServer != nil {
+		routers.GrpcServer.Shutdown()
+		if gracefulStop(grpcServer, time.Until(deadline)) {
+			log.Info("gRPC服务已关闭")
+		} else {
+			log.Warn("gRPC服务等待超时 已强制关闭")
+		}
+	}
+
+	// 等待后台任务
+	if util.WaitBackground(time.Until(deadline)) {
+		log.Info("后台任务已完成")
+	} else {
+		log.Warn("后台任务等待超时")
+	}
+	// 导出剩余 Span
+	ctx, cancel := context.WithTimeout(context.Background(), time.Until(deadline))
+	if err := tracing.Shutdown(ctx); err != nil {
+		log.Error("链路追踪关闭失败: ", err)
+	} else {
+		log.Info("链路追踪已关闭")
+	}
+	cancel()
+
+	// 关闭数据库
+	if err := db.Orm.Close(); err != nil {
+		log.Error("数据库关闭失败: ", err)
+	} else {
+		log.Info("数据库连接已关闭")
+	}
+	// 关闭 redis
+	if err := cs.CloseRedis(); err != nil {
+		log.Error("redis关闭失败: ", err)
+	} else {
+		log.Info("redis连接已关闭")
+	}
+	// 关闭 grpc 全局连接池
+	util.CloseGrpcConns()
+	log.Info("gRPC连接池已关闭")
 	// 关闭etcd客户端
 	if err := cs.CloseEtcdClient(); err != nil {
-		log.Error("etcd客户端关闭失败: %v", err)
+		log.Error("etcd客户端关闭失败: ", err)
 	} else {
 		log.Info("etcd客户端关闭成功")
 	}
-	// 关闭 grpc 全局连接池
-	util.CloseGrpcConns()
+	log.Info("退出完成")
+	_ = log.Close()
 	return nil
 }
+
+// 退出等待时间 默认 30 秒
+func shutdownTimeout() time.Duration {
+	if seconds := env.GetServerConfig().Server.ShutdownTimeout; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// gracefulStop 等待 gRPC 请求完成 超时后强制关闭
+func gracefulStop(server *grpc.Server, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		server.Stop()
+		return false
+	}
+}
@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"math/rand"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/apps/user/models"
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+ * @Desc: 访问日志中间件
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+const redacted = "***"
+
+// 需要脱敏的请求头
+var sensitiveHeaders = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"x-api-key":     true,
+}
+
+// 需要脱敏的请求体与查询参数字段 含邮箱验证码 授权码与 PKCE 校验码
+var sensitiveFields = map[string]bool{
+	"password":      true,
+	"old_password":  true,
+	"new_password":  true,
+	"oldpassword":   true,
+	"newpassword":   true,
+	"code":          true,
+	"email_code":    true,
+	"code_verifier": true,
+	"codeverifier":  true,
+	"token":         true,
+	"access_token":  true,
+	"accesstoken":   true,
+	"refresh_token": true,
+	"refreshtoken":  true,
+	"id_token":      true,
+	"idtoken":       true,
+	"id_token_hint": true,
+	"client_secret": true,
+	"clientsecret":  true,
+	"secret":        true,
+}
+
+// AccessLog 记录请求方法 路由 状态码 耗时 字节数 客户端 IP 用户 请求 ID
+// 按路由模板采样 5xx 始终记录
+func AccessLog() fiber.Handler {
+	conf := env.GetServerConfig().Middleware.AccessLog
+	maxBody := conf.MaxBodyLength
+	if maxBody <= 0 {
+		maxBody = 1024
+	}
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		// 先交给错误处理器写入响应 才能拿到最终状态码
+		if err != nil {
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+		status := c.Response().StatusCode()
+		route := c.Route().Path
+		if status < fiber.StatusInternalServerError && !sampled(conf, route) {
+			return nil
+		}
+
+		fields := map[string]interface{}{
+			"method":     c.Method(),
+			"route":      route,
+			"path":       c.Path(),
+			"status":     status,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes_in":   len(c.Request().Body()),
+			"bytes_out":  len(c.Response().Body()),
+			"ip":         util.GetIP(c),
+			"user_agent": c.Get(fiber.HeaderUserAgent),
+		}
+		if requestId, ok := c.Locals(common.COMMON_REQUEST_ID).(string); ok {
+			fields[log.FIELD_REQUEST_ID] = requestId
+		}
+		if user, ok := c.Locals(common.COMMON_AUTH_CURRENT).(models.CurrentUser); ok {
+			fields["user_id"] = user.ID
+		}
+		if query := string(c.Request().URI().QueryString()); query != "" {
+			fields["query"] = redactForm(query)
+		}
+		if conf.LogHeaders == "on" {
+			fields["headers"] = redactHeaders(c)
+		}
+		if conf.LogBody == "on" {
+			if body := redactBody(c); body != "" {
+				if len(body) > maxBody {
+					body = body[:maxBody] + "..."
+				}
+				fields["body"] = body
+			}
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		log.Access(fields, c.Method()+" "+c.Path())
+		return nil
+	}
+}
+
+// 路由采样率 未单独配置时使用默认值
+func sampled(conf env.AccessLogConfig, route string) bool {
+	rate, ok := conf.RouteSamples[route]
+	if !ok {
+		rate = conf.SampleRate
+		if rate <= 0 {
+			rate = 1
+		}
+	}
+	if rate >= 1 {
+		return true
+	}
+	return rate > 0 && rand.Float64() < rate
+}
+
+// 请求头 敏感头替换为 ***
+func redactHeaders(c *fiber.Ctx) map[string]string {
+	headers := make(map[string]string)
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		name := string(key)
+		if sensitiveHeaders[strings.ToLower(name)] {
+			headers[name] = redacted
+			return
+		}
+		headers[name] = string(value)
+	})
+	return headers
+}
+
+// 请求体 仅记录 JSON 与表单
+func redactBody(c *fiber.Ctx) string {
+	body := c.Request().Body()
+	if len(body) == 0 {
+		return ""
+	}
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+	switch {
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationJSON):
+		var data interface{}
+		if err := sonic.Unmarshal(body, &data); err != nil {
+			return "[无法解析的JSON]"
+		}
+		out, _ := sonic.MarshalString(redactValue(data))
+		return out
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationForm):
+		return redactForm(string(body))
+	}
+	return "[" + contentType + "]"
+}
+
+// 递归脱敏 JSON
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if sensitiveFields[strings.ToLower(key)] {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// 脱敏 a=1&password=2 形式的参数
+func redactForm(raw string) string {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return "[无法解析的参数]"
+	}
+	for key := range values {
+		if sensitiveFields[strings.ToLower(key)] {
+			values[key] = []string{redacted}
+		}
+	}
+	return values.Encode()
+}
@@ -2,14 +2,20 @@ package middleware
 
 import (
 	"bytes"
+	stdctx "context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/common/metrics"
 	"github.com/GoFurry/gofurry-user/roof/env"
 	"github.com/corazawaf/coraza/v3"
 	"github.com/corazawaf/coraza/v3/experimental"
@@ -24,25 +30,31 @@ import (
  * @version: v1.0.0
  */
 
+// CorazaMiddleware 开关与规则文件支持热更新 规则文件路径或内容变更后重新加载 每秒最多检查一次
 func CorazaMiddleware() fiber.Handler {
 	return func(context *fiber.Ctx) (err error) {
+		if env.GetServerConfig().Waf.WafSwitch != "on" {
+			return context.Next()
+		}
 
-		// 初始化 WAF 和 SecLang 解析器
-		waf, err := createWAF()
+		// 按规则文件缓存 WAF
+		waf, err := loadWAF()
 		// 检查错误
 		if err != nil {
-			fmt.Println(err)
-			return
+			log.ErrorCtx(context.UserContext(), "WAF初始化失败: ", err)
+			return common.NewResponse(context).ErrorWithCode("WAF初始化失败", http.StatusInternalServerError)
 		}
 
 		// 事件句柄匿名函数
 		newTX := func(*http.Request) types.Transaction {
 			return waf.NewTransaction()
 		}
-		// 事件句柄匿名函数
+		// 事件句柄匿名函数 事务 ID 使用请求 ID 规则命中日志据此关联请求
 		if ctxwaf, ok := waf.(experimental.WAFWithOptions); ok {
+			requestId, _ := context.Locals(common.COMMON_REQUEST_ID).(string)
 			newTX = func(r *http.Request) types.Transaction {
 				return ctxwaf.NewTransactionWithOptions(experimental.Options{
+					ID:      requestId,
 					Context: r.Context(),
 				})
 			}
@@ -66,7 +78,7 @@ func CorazaMiddleware() fiber.Handler {
 
 		// 没开规则就返回
 		if tx.IsRuleEngineOff() {
-			context.Next()
+			return context.Next()
 		}
 
 		// 处理请求
@@ -74,6 +86,7 @@ func CorazaMiddleware() fiber.Handler {
 			tx.DebugLogger().Error().Err(err).Msg("Failed to process request")
 			return common.NewResponse(context).ErrorWithCode("WAF处理请求失败", http.StatusInternalServerError)
 		} else if it != nil {
+			metrics.WafInterruption(it.RuleID, it.Action)
 			status := obtainStatusCodeFromInterruptionOrDefault(it, http.StatusOK)
 			context.Status(status)
 			return common.NewResponse(context).ErrorWithCode("WAF拦截", status)
@@ -83,13 +96,96 @@ func CorazaMiddleware() fiber.Handler {
 	}
 }
 
-// 创建WAF
-func createWAF() (coraza.WAF, error) {
+// 规则文件版本 路径 修改时间与大小均相同视为未变化
+type wafKey struct {
+	path    string
+	modTime int64
+	size    int64
+}
+
+type loadedWAF struct {
+	key     wafKey // 当前规则对应的文件版本
+	failed  wafKey // 最近一次加载失败的文件版本 文件未再变化时不重复加载
+	waf     coraza.WAF
+	checked time.Time
+}
+
+// 规则文件检查间隔 避免每个请求都读取文件状态
+const wafCheckInterval = time.Second
+
+var (
+	wafMu     sync.Mutex
+	wafLoaded atomic.Pointer[loadedWAF]
+)
+
+// loadWAF 规则文件路径或内容变化时重新创建 失败时沿用上一次成功加载的规则
+// 失败的文件修正后在下次检查时重新加载
+func loadWAF() (coraza.WAF, error) {
 	directivesFile := env.GetServerConfig().Waf.ConfPath
 	if s := os.Getenv("DIRECTIVES_FILE"); s != "" {
 		directivesFile = s
 	}
+	if loaded := wafLoaded.Load(); loaded.fresh(directivesFile) {
+		return loaded.waf, nil
+	}
 
+	wafMu.Lock()
+	defer wafMu.Unlock()
+	loaded := wafLoaded.Load()
+	if loaded.fresh(directivesFile) {
+		return loaded.waf, nil
+	}
+	key, err := statWAF(directivesFile)
+	if err == nil && loaded != nil && (key == loaded.key || key == loaded.failed) {
+		next := *loaded
+		next.checked = time.Now()
+		wafLoaded.Store(&next)
+		return loaded.waf, nil
+	}
+	var waf coraza.WAF
+	if err == nil {
+		waf, err = createWAF(directivesFile)
+	}
+	if err != nil {
+		if loaded != nil {
+			if key != loaded.failed {
+				log.Error("WAF规则加载失败 沿用 ", loaded.key.path, ": ", err)
+			}
+			next := *loaded
+			next.failed = key
+			next.checked = time.Now()
+			wafLoaded.Store(&next)
+			return loaded.waf, nil
+		}
+		return nil, err
+	}
+	wafLoaded.Store(&loadedWAF{key: key, waf: waf, checked: time.Now()})
+	log.Info("WAF规则已加载: ", directivesFile)
+	return waf, nil
+}
+
+// fresh 检查间隔内且路径未变 直接使用缓存
+func (loaded *loadedWAF) fresh(path string) bool {
+	if loaded == nil || time.Since(loaded.checked) >= wafCheckInterval {
+		return false
+	}
+	return loaded.key.path == path || loaded.failed.path == path
+}
+
+// statWAF 读取规则文件版本 文件不存在时返回错误 版本仅含路径
+func statWAF(path string) (wafKey, error) {
+	key := wafKey{path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return key, err
+	}
+	key.modTime = info.ModTime().UnixNano()
+	key.size = info.Size()
+	return key, nil
+}
+
+// 创建WAF
+func createWAF(directivesFile string) (coraza.WAF, error) {
 	waf, err := coraza.NewWAF(
 		coraza.NewWAFConfig().
 			WithErrorCallback(logError).
@@ -99,10 +195,10 @@ func createWAF() (coraza.WAF, error) {
 	return waf, err
 }
 
-// WAF 错误日志
+// WAF 错误日志 事务 ID 即请求 ID
 func logError(error types.MatchedRule) {
 	msg := error.ErrorLog()
-	fmt.Printf("[logError][%s] %s\n", error.Rule().Severity(), msg)
+	log.WarnCtx(log.WithRequestId(stdctx.Background(), error.TransactionID()), "[", error.Rule().Severity(), "] ", msg)
 }
 
 // 处理请求
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+)
+
+/*
+ * @Desc: 跨域中间件 允许的来源支持热更新
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+// Cors 未配置 allow_origins 时允许所有来源
+func Cors() fiber.Handler {
+	return reloadable(func() string {
+		return env.GetServerConfig().Middleware.Cors.AllowOrigins
+	}, func() fiber.Handler {
+		origins := env.GetServerConfig().Middleware.Cors.AllowOrigins
+		if origins == "" || origins == "*" {
+			return cors.New()
+		}
+		return cors.New(cors.Config{
+			AllowOrigins:     origins,
+			AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
+			AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+			AllowCredentials: true,
+		})
+	})
+}
@@ -20,7 +20,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// JWTMiddleWare Fiber版本JWT鉴权中间件
+// JWTMiddleWare Fiber版本JWT鉴权中间件 同时接受个人访问令牌
 func JWTMiddleWare() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Authorization
@@ -33,9 +33,14 @@ func JWTMiddleWare() fiber.Handler {
 			})
 		}
 
+		// 个人访问令牌
+		if pat, ok := asPersonalToken(authorization); ok {
+			return personalTokenAuth(c, pat)
+		}
+
 		// 从Redis获取有效token
 		token := authorization
-		cache, err := cs.GetString("jwt:" + token)
+		cache, err := cs.GetStringCtx(c.UserContext(), "jwt:"+token)
 		if err != nil || strings.TrimSpace(cache) == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"code":    fiber.StatusUnauthorized,
@@ -47,7 +52,7 @@ func JWTMiddleWare() fiber.Handler {
 		// 解析JWT token
 		claims, pe := util.ParseToken(token)
 		if pe != nil {
-			log.Error(pe)
+			log.ErrorCtx(c.UserContext(), pe)
 			// 根据错误类型返回对应信息
 			switch {
 			case errors.Is(pe, jwt.ErrTokenMalformed):
@@ -77,7 +82,7 @@ func JWTMiddleWare() fiber.Handler {
 		if claims.ExpiresAt.Sub(time.Now()) < common.JWT_RELET_NUM*time.Hour {
 			newTokenStr, err := util.NewToken(claims.UserId, claims.UserName)
 			if err == nil {
-				cs.SetExpire(authorization, newTokenStr, common.JWT_RELET_NUM*time.Hour)
+				cs.SetExpireCtx(c.UserContext(), authorization, newTokenStr, common.JWT_RELET_NUM*time.Hour)
 			}
 		}
 
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/common/metrics"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+ * @Desc: HTTP 指标中间件
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+// Metrics 按路由模板与状态码统计请求数与耗时
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		// 先交给错误处理器写入响应 才能拿到最终状态码
+		if err != nil {
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+		metrics.ObserveHttp(c.Method(), c.Route().Path, c.Response().StatusCode(), time.Since(start))
+		return nil
+	}
+}
+
+// MetricsAuth 配置了 middleware.metrics.secret 时校验 Bearer 令牌
+func MetricsAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		secret := env.GetServerConfig().Middleware.Metrics.Secret
+		if secret == "" {
+			return c.Next()
+		}
+		token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+/*
+ * @Desc: 角色权限校验 权限定义见 auth.role_permissions
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"github.com/GoFurry/gofurry-user/apps/user/models"
+	"github.com/GoFurry/gofurry-user/apps/user/service"
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission 需在 JWTMiddleWare 之后使用 当前用户角色无此权限时拒绝
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := c.Locals(common.COMMON_AUTH_CURRENT).(models.CurrentUser)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"code":    fiber.StatusUnauthorized,
+				"message": "用户未登录.",
+			})
+		}
+		allowed, reason, err := service.GetUserService().CheckPermission(c.UserContext(), user.ID, permission)
+		if err != nil {
+			return common.NewResponse(c).Error(err.GetMsg())
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"code":    fiber.StatusForbidden,
+				"message": reason,
+			})
+		}
+		return c.Next()
+	}
+}
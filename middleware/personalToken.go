@@ -0,0 +1,62 @@
+package middleware
+
+/*
+ * @Desc: 个人访问令牌鉴权
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"strings"
+
+	"github.com/GoFurry/gofurry-user/apps/user/models"
+	"github.com/GoFurry/gofurry-user/apps/user/service"
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/util"
+	"github.com/gofiber/fiber/v2"
+)
+
+// 是否个人访问令牌 兼容 Bearer 前缀
+func asPersonalToken(authorization string) (string, bool) {
+	token := authorization
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	return token, strings.HasPrefix(token, common.PAT_PREFIX)
+}
+
+// personalTokenAuth 校验个人访问令牌并设置当前用户
+func personalTokenAuth(c *fiber.Ctx, token string) error {
+	record, user, err := service.GetUserService().ValidatePersonalToken(c.UserContext(), token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"code":    fiber.StatusUnauthorized,
+			"message": err.GetMsg(),
+		})
+	}
+	c.Locals(common.COMMON_AUTH_CURRENT, models.CurrentUser{
+		ID:   user.ID,
+		Name: user.Name,
+	})
+	// 空 scope 表示无任何权限 旧令牌须重新创建
+	scopes := strings.Fields(record.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	c.Locals(common.COMMON_AUTH_SCOPES, scopes)
+	return c.Next()
+}
+
+// RequireScope 限制个人访问令牌的权限范围 登录凭证不受限制 未授予 scope 的令牌一律拒绝
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, ok := c.Locals(common.COMMON_AUTH_SCOPES).([]string)
+		if !ok || util.In(scope, scopes) {
+			return c.Next()
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"code":    fiber.StatusForbidden,
+			"message": "令牌无此权限: " + scope,
+		})
+	}
+}
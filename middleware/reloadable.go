@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+ * @Desc: 随配置热更新重建的中间件
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+type builtHandler struct {
+	key     string
+	handler fiber.Handler
+}
+
+// reloadable key 为相关配置的摘要 变化时用 build 重建 中间件内部状态随之重置
+func reloadable(key func() string, build func() fiber.Handler) fiber.Handler {
+	var (
+		mu      sync.Mutex
+		current atomic.Pointer[builtHandler]
+	)
+	return func(c *fiber.Ctx) error {
+		k := key()
+		built := current.Load()
+		if built == nil || built.key != k {
+			mu.Lock()
+			if built = current.Load(); built == nil || built.key != k {
+				built = &builtHandler{key: k, handler: build()}
+				current.Store(built)
+			}
+			mu.Unlock()
+		}
+		return built.handler(c)
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+/*
+ * @Desc: 请求 ID 中间件
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+// 上游传入的请求 ID 最大长度
+const maxRequestIdLength = 64
+
+// RequestId 沿用上游 X-Request-ID 没有或不合法时生成
+// 写入响应头 Locals 与 UserContext 日志通过 log.XxxCtx 输出该 ID
+func RequestId() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestId := c.Get(fiber.HeaderXRequestID)
+		if !validRequestId(requestId) {
+			requestId = utils.UUIDv4()
+		}
+		c.Set(fiber.HeaderXRequestID, requestId)
+		c.Locals(common.COMMON_REQUEST_ID, requestId)
+		c.SetUserContext(log.WithRequestId(c.UserContext(), requestId))
+		return c.Next()
+	}
+}
+
+// 只接受字母 数字 - _ . 避免日志注入
+func validRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for _, ch := range requestId {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-' || ch == '_' || ch == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -1,13 +1,25 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/common/metrics"
+	"github.com/GoFurry/gofurry-user/common/tracing"
 	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"log"
-	"sync"
-	"time"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+	otelgorm "gorm.io/plugin/opentelemetry/tracing"
 )
 
 /*
@@ -16,6 +28,19 @@ import (
  * @version: v1.0.0
  */
 
+// REPLICA 只读副本 查询时通过 dbresolver.Use(REPLICA) 指定 未配置副本时使用主库
+const REPLICA = "replica"
+
+const (
+	defaultMaxIdleConns    = 100
+	defaultMaxOpenConns    = 1000
+	defaultConnMaxLifetime = 60   // 秒
+	defaultConnectTimeout  = 5    // 秒
+	defaultConnectRetries  = 5    // 次
+	defaultConnectBackoff  = 1000 // 毫秒
+	maxConnectBackoff      = 30 * time.Second
+)
+
 var Orm = &orm{}
 var once sync.Once
 
@@ -24,7 +49,14 @@ func initOrm() {
 }
 
 type orm struct {
-	engine *gorm.DB
+	engine   *gorm.DB
+	replicas []replica
+}
+
+// 只读副本连接池 启动检查与关闭时使用
+type replica struct {
+	addr string
+	pool *sql.DB
 }
 
 func (db *orm) loadDBConfig() {
@@ -32,26 +64,212 @@ func (db *orm) loadDBConfig() {
 		return
 	}
 	var err error
-	var dsn string
 
-	pgsql := env.GetServerConfig().DataBase
-	dsn = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", pgsql.DBHost, pgsql.DBPort, pgsql.DBUsername, pgsql.DBPassword, pgsql.DBName)
-	db.engine, err = gorm.Open(postgres.Open(dsn))
+	conf := env.GetServerConfig().DataBase
+	dialector := postgres.Open(buildDSN(conf, conf.DBHost, conf.DBPort))
+	if conf.DriverName() == "sqlite" {
+		dialector = sqlite.Open(sqliteDSN(conf))
+	}
+	// 不在初始化时连接 启动时由 Connect 检查 check-config 等命令无需数据库
+	db.engine, err = gorm.Open(dialector, &gorm.Config{
+		DisableAutomaticPing: true,
+		// 慢查询与错误输出到统一日志
+		Logger: log.GormLogger(logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+		}),
+	})
 	if err != nil {
 		log.Fatal("open database error: " + err.Error())
 	}
 
-	sqlDB, _ := db.engine.DB()
-	sqlDB.SetMaxIdleConns(100)                 // SetMaxIdleConns 设置空闲连接池中连接的最大数量。
-	sqlDB.SetMaxOpenConns(1000)                // SetMaxOpenConns 设置打开数据库连接的最大数量。
-	sqlDB.SetConnMaxLifetime(60 * time.Second) // SetConnMaxLifetime 设置了可以重新使用连接的最大时间。
-	err = sqlDB.Ping()
+	if err = db.engine.Use(&metrics.GormPlugin{}); err != nil {
+		log.Fatal("register gorm metrics error: " + err.Error())
+	}
+	// 仅记录请求链路中的语句 不记录参数值
+	err = db.engine.Use(otelgorm.NewPlugin(
+		otelgorm.WithTracerProvider(tracing.ChildOnlyProvider()),
+		otelgorm.WithoutQueryVariables(),
+		otelgorm.WithoutMetrics(),
+	))
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal("register gorm tracing error: " + err.Error())
+	}
+
+	sqlDB, _ := db.engine.DB()
+	setPool(sqlDB, conf)
+	if conf.InMemory() {
+		// 内存库随连接关闭而消失 只保留一个常驻连接
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+
+	if err = db.registerReplicas(conf); err != nil {
+		log.Fatal("register database replicas error: " + err.Error())
+	}
+}
+
+// registerReplicas 注册只读副本 副本与主库使用相同的账号 库名与连接参数
+// 仅显式指定 REPLICA 的查询走副本 写入与事务始终在主库
+func (db *orm) registerReplicas(conf env.DataBaseConfig) error {
+	if len(conf.Replicas) == 0 {
+		return nil
+	}
+	dialectors := make([]gorm.Dialector, 0, len(conf.Replicas))
+	for _, addr := range conf.Replicas {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("副本地址无效 %s: %w", addr, err)
+		}
+		pool, err := sql.Open("pgx", buildDSN(conf, host, port))
+		if err != nil {
+			return err
+		}
+		setPool(pool, conf)
+		db.replicas = append(db.replicas, replica{addr: addr, pool: pool})
+		dialectors = append(dialectors, postgres.New(postgres.Config{Conn: pool}))
+	}
+	return db.engine.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   dbresolver.RandomPolicy{},
+	}, REPLICA))
+}
+
+// buildDSN 拼接连接串 值统一加引号 密码中的空格与引号不影响解析
+func buildDSN(conf env.DataBaseConfig, host string, port string) string {
+	sslMode := conf.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	params := [][2]string{
+		{"host", host},
+		{"port", port},
+		{"user", conf.DBUsername},
+		{"password", conf.DBPassword},
+		{"dbname", conf.DBName},
+		{"sslmode", sslMode},
+		{"sslrootcert", conf.SSLRootCert},
+		{"sslcert", conf.SSLCert},
+		{"sslkey", conf.SSLKey},
+		{"connect_timeout", fmt.Sprint(withDefault(conf.ConnectTimeout, defaultConnectTimeout))},
+	}
+	// 服务端参数 超时的语句由数据库取消
+	if conf.StatementTimeout > 0 {
+		params = append(params, [2]string{"statement_timeout", fmt.Sprint(conf.StatementTimeout)})
+	}
+	parts := make([]string, 0, len(params))
+	for _, param := range params {
+		if param[1] == "" {
+			continue
+		}
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(param[1])
+		parts = append(parts, param[0]+"='"+value+"'")
 	}
+	return strings.Join(parts, " ")
+}
+
+// sqliteDSN 开启外键约束 写锁等待 5 秒 文件库使用 WAL 读写互不阻塞
+func sqliteDSN(conf env.DataBaseConfig) string {
+	pragmas := "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if conf.InMemory() {
+		return "file::memory:?" + pragmas
+	}
+	return "file:" + conf.DBPath + "?" + pragmas + "&_pragma=journal_mode(WAL)"
+}
+
+// setPool 连接池参数 未配置时使用默认值
+func setPool(pool *sql.DB, conf env.DataBaseConfig) {
+	pool.SetMaxIdleConns(withDefault(conf.MaxIdleConns, defaultMaxIdleConns))                                       // 空闲连接池中连接的最大数量
+	pool.SetMaxOpenConns(withDefault(conf.MaxOpenConns, defaultMaxOpenConns))                                       // 打开数据库连接的最大数量
+	pool.SetConnMaxLifetime(time.Duration(withDefault(conf.ConnMaxLifetime, defaultConnMaxLifetime)) * time.Second) // 连接可重复使用的最长时间
+	if conf.ConnMaxIdleTime > 0 {
+		pool.SetConnMaxIdleTime(time.Duration(conf.ConnMaxIdleTime) * time.Second) // 空闲连接保留时间
+	}
+}
+
+func withDefault(value int, def int) int {
+	if value > 0 {
+		return value
+	}
+	return def
 }
 
 func (db *orm) DB() *gorm.DB {
 	once.Do(initOrm)
 	return db.engine
 }
+
+// Close 关闭连接池 未初始化时跳过
+func (db *orm) Close() error {
+	if db.engine == nil {
+		return nil
+	}
+	sqlDB, err := db.engine.DB()
+	if err != nil {
+		return err
+	}
+	errs := []error{sqlDB.Close()}
+	for _, item := range db.replicas {
+		errs = append(errs, item.pool.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Ping 检查数据库连接
+func (db *orm) Ping(ctx context.Context) error {
+	sqlDB, err := db.DB().DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// PingReplicas 检查全部只读副本 未配置副本时返回 nil
+func (db *orm) PingReplicas(ctx context.Context) error {
+	db.DB()
+	var errs []error
+	for _, item := range db.replicas {
+		if err := item.pool.PingContext(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", item.addr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Connect 启动时检查主库连接 失败后按退避间隔重试 间隔逐次翻倍 最长 30 秒
+// 副本不可用时仅告警 不影响启动
+func (db *orm) Connect(ctx context.Context) error {
+	conf := env.GetServerConfig().DataBase
+	retries := withDefault(conf.ConnectRetries, defaultConnectRetries)
+	backoff := time.Duration(withDefault(conf.ConnectBackoff, defaultConnectBackoff)) * time.Millisecond
+	timeout := time.Duration(withDefault(conf.ConnectTimeout, defaultConnectTimeout)) * time.Second
+
+	for attempt := 0; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := db.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			break
+		}
+		if attempt >= retries {
+			return fmt.Errorf("重试 %d 次后仍失败: %w", retries, err)
+		}
+		log.Warn(fmt.Sprintf("数据库连接失败 %s 后第 %d/%d 次重试: %v", backoff, attempt+1, retries, err))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := db.PingReplicas(pingCtx); err != nil {
+		log.Warn("数据库只读副本不可用: ", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package db
+
+/*
+ * @Desc: 约束错误 postgres 与 sqlite 的错误码归为同一类型
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"errors"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// 约束类型
+const (
+	VIOLATION_NOT_NULL    = "not_null"
+	VIOLATION_UNIQUE      = "unique"
+	VIOLATION_FOREIGN_KEY = "foreign_key"
+	VIOLATION_CHECK       = "check"
+)
+
+// postgres SQLSTATE
+var pgViolations = map[string]string{
+	"23502": VIOLATION_NOT_NULL,
+	"23505": VIOLATION_UNIQUE,
+	"23503": VIOLATION_FOREIGN_KEY,
+	"23514": VIOLATION_CHECK,
+}
+
+// sqlite 扩展错误码 主键冲突按唯一约束处理
+var sqliteViolations = map[int]string{
+	1299: VIOLATION_NOT_NULL,    // SQLITE_CONSTRAINT_NOTNULL
+	2067: VIOLATION_UNIQUE,      // SQLITE_CONSTRAINT_UNIQUE
+	1555: VIOLATION_UNIQUE,      // SQLITE_CONSTRAINT_PRIMARYKEY
+	787:  VIOLATION_FOREIGN_KEY, // SQLITE_CONSTRAINT_FOREIGNKEY
+	275:  VIOLATION_CHECK,       // SQLITE_CONSTRAINT_CHECK
+}
+
+// Violation 违反的约束类型 不是约束错误时返回空
+func Violation(err error) string {
+	var pe *pgconn.PgError
+	if errors.As(err, &pe) {
+		return pgViolations[pe.Code]
+	}
+	var se *gosqlite.Error
+	if errors.As(err, &se) {
+		return sqliteViolations[se.Code()]
+	}
+	return ""
+}
@@ -0,0 +1,298 @@
+package db
+
+/*
+ * @Desc: 数据库迁移 内嵌 migrations/<版本>_<名称>.up.sql 与 .down.sql 按版本顺序执行
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const (
+	migrationTable = "schema_migrations"
+	// 多实例同时启动时只有一个执行迁移
+	migrationLockId = 7408245901
+)
+
+var migrationName = regexp.MustCompile(`^(\d+)_([\w-]+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移 Checksum 为 up 与 down 脚本的 SHA-256
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus 迁移执行状态 未执行时 AppliedAt 为空
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// 已执行的迁移记录
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// loadMigrations 读取内嵌的迁移脚本 按版本排序
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("迁移文件名无效 %s 应为 <版本>_<名称>.up.sql", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("迁移版本重复 %d: %s %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("迁移 %s 缺少 up 脚本", m)
+		}
+		// 回滚脚本同样不可修改 以 NUL 分隔避免内容拼接后相同
+		sum := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+		m.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate 执行全部未执行的迁移 每个版本一个事务 已执行的脚本被修改时拒绝执行
+func (db *orm) Migrate(ctx context.Context) (applied []Migration, err error) {
+	err = db.withMigrationLock(ctx, func(conn *gorm.DB, migrations []Migration, done map[int64]appliedMigration) error {
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Up).Error; err != nil {
+					return err
+				}
+				return tx.Exec("INSERT INTO "+migrationTable+" (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+					m.Version, m.Name, m.Checksum, time.Now()).Error
+			})
+			if err != nil {
+				return fmt.Errorf("迁移 %s 执行失败: %w", m, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Rollback 按版本倒序回滚最近 steps 个已执行的迁移
+func (db *orm) Rollback(ctx context.Context, steps int) (rolled []Migration, err error) {
+	err = db.withMigrationLock(ctx, func(conn *gorm.DB, migrations []Migration, done map[int64]appliedMigration) error {
+		for i := len(migrations) - 1; i >= 0 && len(rolled) < steps; i-- {
+			m := migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("迁移 %s 缺少 down 脚本 无法回滚", m)
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Down).Error; err != nil {
+					return err
+				}
+				return tx.Exec("DELETE FROM "+migrationTable+" WHERE version = ?", m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("迁移 %s 回滚失败: %w", m, err)
+			}
+			rolled = append(rolled, m)
+		}
+		return nil
+	})
+	return rolled, err
+}
+
+// MigrationStatus 全部迁移的执行状态 已执行的脚本被修改时返回错误
+func (db *orm) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	// 仅查询 不创建迁移表
+	conn := db.DB().WithContext(ctx)
+	done := make(map[int64]appliedMigration)
+	if conn.Migrator().HasTable(migrationTable) {
+		if done, err = appliedMigrations(conn); err != nil {
+			return nil, err
+		}
+	}
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		item := MigrationStatus{Migration: m}
+		if record, ok := done[m.Version]; ok {
+			appliedAt := record.AppliedAt
+			item.AppliedAt = &appliedAt
+		}
+		status = append(status, item)
+	}
+	return status, verifyMigrations(migrations, done)
+}
+
+// withMigrationLock 在同一连接上加锁后读取迁移记录并校验
+func (db *orm) withMigrationLock(ctx context.Context, fn func(conn *gorm.DB, migrations []Migration, done map[int64]appliedMigration) error) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	return db.DB().WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if conn.Dialector.Name() == "postgres" {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockId).Error; err != nil {
+				return fmt.Errorf("获取迁移锁失败: %w", err)
+			}
+			// 连接归还连接池前释放 ctx 已取消时也要执行
+			defer conn.WithContext(context.Background()).Exec("SELECT pg_advisory_unlock(?)", migrationLockId)
+		}
+		if err := ensureMigrationTable(conn); err != nil {
+			return err
+		}
+		done, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		if err = verifyMigrations(migrations, done); err != nil {
+			return err
+		}
+		return fn(conn, migrations, done)
+	})
+}
+
+func ensureMigrationTable(conn *gorm.DB) error {
+	err := conn.Exec(`CREATE TABLE IF NOT EXISTS ` + migrationTable + ` (
+    version    BIGINT       NOT NULL PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    checksum   VARCHAR(64)  NOT NULL,
+    applied_at TIMESTAMP    NOT NULL
+)`).Error
+	if err != nil {
+		return fmt.Errorf("创建 %s 失败: %w", migrationTable, err)
+	}
+	return nil
+}
+
+func appliedMigrations(conn *gorm.DB) (map[int64]appliedMigration, error) {
+	var records []appliedMigration
+	if err := conn.Table(migrationTable).Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", migrationTable, err)
+	}
+	done := make(map[int64]appliedMigration, len(records))
+	for _, record := range records {
+		done[record.Version] = record
+	}
+	return done, nil
+}
+
+// verifyMigrations 已执行的脚本不可修改 数据库中的版本须存在于程序中
+func verifyMigrations(migrations []Migration, done map[int64]appliedMigration) error {
+	known := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	var errs []error
+	for version, record := range done {
+		m, ok := known[version]
+		if !ok {
+			errs = append(errs, fmt.Errorf("迁移 %04d_%s 已执行 但程序中不存在 数据库版本可能高于程序", version, record.Name))
+			continue
+		}
+		if m.Checksum != record.Checksum {
+			errs = append(errs, fmt.Errorf("迁移 %s 校验和不一致 已执行的脚本不可修改 请新增迁移", m))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// MigrateOnStart 开启 database.auto_migrate 或使用 sqlite 内存库时执行迁移 否则仅提示未执行的版本
+func (db *orm) MigrateOnStart(ctx context.Context) error {
+	conf := env.GetServerConfig().DataBase
+	if conf.AutoMigrate == "on" || conf.InMemory() {
+		applied, err := db.Migrate(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			log.Info("数据库迁移完成: ", migrationNames(applied))
+		}
+		return nil
+	}
+	status, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []Migration
+	for _, item := range status {
+		if item.AppliedAt == nil {
+			pending = append(pending, item.Migration)
+		}
+	}
+	if len(pending) > 0 {
+		log.Warn("存在未执行的数据库迁移: ", migrationNames(pending), " 请执行 gf-user migrate")
+	}
+	return nil
+}
+
+// 迁移日志中的版本列表
+func migrationNames(migrations []Migration) string {
+	names := make([]string, 0, len(migrations))
+	for _, m := range migrations {
+		names = append(names, m.String())
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useMemoryDB 使用 sqlite 内存库替换 Orm 的连接 测试结束后关闭
+func useMemoryDB(t *testing.T) {
+	t.Helper()
+	conf := env.DataBaseConfig{Driver: "sqlite", DBPath: ":memory:"}
+	engine, err := gorm.Open(sqlite.Open(sqliteDSN(conf)), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := engine.DB()
+	sqlDB.SetMaxOpenConns(1)
+	Orm.engine = engine
+	t.Cleanup(func() {
+		_ = Orm.Close()
+		Orm.engine = nil
+	})
+}
+
+func TestMigrateUniqueEmail(t *testing.T) {
+	useMemoryDB(t)
+	ctx := context.Background()
+
+	applied, err := Orm.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	migrations, _ := loadMigrations()
+	if len(applied) != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	// 再次执行不应重复迁移
+	if applied, err = Orm.Migrate(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("second migrate: applied %d, err %v", len(applied), err)
+	}
+
+	insert := func(id int64) error {
+		now := time.Now()
+		return Orm.DB().Exec(`INSERT INTO gf_user (id, name, nickname, email, password, create_time, update_time, status, avatar)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, id, "fox", "fox", "fox@example.com", "x", now, now, "active", "").Error
+	}
+	if err = insert(1); err != nil {
+		t.Fatalf("insert first user: %v", err)
+	}
+	err = insert(2)
+	if err == nil {
+		t.Fatal("duplicate email inserted")
+	}
+	if got := Violation(err); got != VIOLATION_UNIQUE {
+		t.Fatalf("Violation(%v) = %q, want %q", err, got, VIOLATION_UNIQUE)
+	}
+}
+
+// 0001 使用 IF NOT EXISTS 创建 回滚时不应删除已有的用户表
+func TestRollbackKeepsExistingTables(t *testing.T) {
+	useMemoryDB(t)
+	ctx := context.Background()
+
+	if _, err := Orm.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	migrations, _ := loadMigrations()
+	rolled, err := Orm.Rollback(ctx, len(migrations))
+	if err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if len(rolled) != len(migrations) {
+		t.Fatalf("rolled back %d migrations, want %d", len(rolled), len(migrations))
+	}
+	migrator := Orm.DB().Migrator()
+	for _, table := range []string{"gf_user", "gf_login_log", "gf_user_oauth"} {
+		if !migrator.HasTable(table) {
+			t.Errorf("table %s dropped by rollback", table)
+		}
+	}
+	for _, table := range []string{"gf_user_token", "gf_oauth_client"} {
+		if migrator.HasTable(table) {
+			t.Errorf("table %s not dropped by rollback", table)
+		}
+	}
+}
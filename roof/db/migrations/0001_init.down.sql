@@ -0,0 +1,3 @@
+-- up 使用 IF NOT EXISTS 已有的 gf_user gf_login_log gf_user_oauth 不由本迁移创建 回滚时保留
+DROP TABLE IF EXISTS gf_oauth_client;
+DROP TABLE IF EXISTS gf_user_token;
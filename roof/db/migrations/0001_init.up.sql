@@ -0,0 +1,58 @@
+-- 初始表结构 已有数据库中的表保持不变
+CREATE TABLE IF NOT EXISTS gf_user (
+    id          BIGINT       NOT NULL PRIMARY KEY,
+    name        VARCHAR(100) NOT NULL,
+    nickname    VARCHAR(60)  NOT NULL,
+    email       VARCHAR(100),
+    oauth       BOOLEAN      NOT NULL DEFAULT FALSE,
+    password    VARCHAR(255) NOT NULL,
+    role        VARCHAR(50),
+    info        VARCHAR(255),
+    create_time TIMESTAMP    NOT NULL,
+    update_time TIMESTAMP    NOT NULL,
+    status      VARCHAR(20)  NOT NULL,
+    avatar      VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS gf_login_log (
+    id          BIGINT       NOT NULL PRIMARY KEY,
+    user_id     BIGINT       NOT NULL,
+    agent       VARCHAR(255) NOT NULL,
+    ip          VARCHAR(255) NOT NULL,
+    create_time TIMESTAMP    NOT NULL,
+    login_type  VARCHAR(20)  NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS gf_user_oauth (
+    id          BIGINT       NOT NULL PRIMARY KEY,
+    user_id     BIGINT       NOT NULL,
+    provider    VARCHAR(50)  NOT NULL,
+    open_id     VARCHAR(255) NOT NULL,
+    create_time TIMESTAMP    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS gf_user_token (
+    id             BIGINT       NOT NULL PRIMARY KEY,
+    name           VARCHAR(100) NOT NULL,
+    user_id        BIGINT       NOT NULL,
+    token_hash     VARCHAR(64)  NOT NULL,
+    token_prefix   VARCHAR(16)  NOT NULL,
+    scopes         VARCHAR(255) NOT NULL,
+    expire_time    TIMESTAMP,
+    last_used_time TIMESTAMP,
+    revoked        BOOLEAN      NOT NULL DEFAULT FALSE,
+    create_time    TIMESTAMP    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS gf_oauth_client (
+    id            BIGINT       NOT NULL PRIMARY KEY,
+    name          VARCHAR(100) NOT NULL,
+    client_id     VARCHAR(64)  NOT NULL,
+    client_secret VARCHAR(128),
+    redirect_uris TEXT         NOT NULL,
+    scopes        VARCHAR(255) NOT NULL,
+    public        BOOLEAN      NOT NULL DEFAULT FALSE,
+    status        VARCHAR(20)  NOT NULL,
+    create_time   TIMESTAMP    NOT NULL,
+    update_time   TIMESTAMP    NOT NULL
+);
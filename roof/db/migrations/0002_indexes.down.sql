@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_gf_user_token_user_id;
+DROP INDEX IF EXISTS idx_gf_user_oauth_user_id;
+DROP INDEX IF EXISTS idx_gf_login_log_user_id;
+DROP INDEX IF EXISTS idx_gf_user_name;
+
+DROP INDEX IF EXISTS uk_gf_oauth_client_client_id;
+DROP INDEX IF EXISTS uk_gf_user_token_hash;
+DROP INDEX IF EXISTS uk_gf_user_oauth_provider_open_id;
+DROP INDEX IF EXISTS uk_gf_user_email;
@@ -0,0 +1,12 @@
+-- 唯一约束 存在重复数据时迁移失败 需先清理重复记录
+-- 三方登录账户 email 为空 不受唯一约束限制
+CREATE UNIQUE INDEX IF NOT EXISTS uk_gf_user_email ON gf_user (email);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_gf_user_oauth_provider_open_id ON gf_user_oauth (provider, open_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_gf_user_token_hash ON gf_user_token (token_hash);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_gf_oauth_client_client_id ON gf_oauth_client (client_id);
+
+-- 查询索引
+CREATE INDEX IF NOT EXISTS idx_gf_user_name ON gf_user (name);
+CREATE INDEX IF NOT EXISTS idx_gf_login_log_user_id ON gf_login_log (user_id, create_time);
+CREATE INDEX IF NOT EXISTS idx_gf_user_oauth_user_id ON gf_user_oauth (user_id);
+CREATE INDEX IF NOT EXISTS idx_gf_user_token_user_id ON gf_user_token (user_id, revoked);
@@ -0,0 +1 @@
+ALTER TABLE gf_oauth_client DROP COLUMN trusted;
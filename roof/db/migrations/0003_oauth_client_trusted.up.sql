@@ -0,0 +1,2 @@
+-- 可信客户端 复用登录会话直接签发授权码 不需要用户确认
+ALTER TABLE gf_oauth_client ADD COLUMN trusted BOOLEAN NOT NULL DEFAULT FALSE;
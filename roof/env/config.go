@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/GoFurry/gofurry-user/common"
 	"gopkg.in/yaml.v2"
@@ -32,11 +33,122 @@ type serverConfig struct {
 	Resource   ResourceConfig   `yaml:"resource"`
 	Etcd       EtcdConfig       `yaml:"etcd"`
 	Auth       AuthConfig       `yaml:"auth"`
+	Idp        IdpConfig        `yaml:"idp"`
+	GrpcServer GrpcServerConfig `yaml:"grpc_server"`
+	Endpoint   EndpointConfig   `yaml:"endpoint"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Feature    FeatureConfig    `yaml:"feature"`
+	Dynamic    DynamicConfig    `yaml:"dynamic"`
+	// 按服务名配置 gRPC 客户端 如 github-oauth-service
+	GrpcClients map[string]GrpcClientConfig `yaml:"grpc_clients"`
+}
+
+type GrpcClientConfig struct {
+	Insecure   bool   `yaml:"insecure"`    // 明确声明不使用 TLS 仅限内网调试
+	CaFile     string `yaml:"ca_file"`     // 校验服务端的 CA 证书 为空使用系统根证书
+	CertFile   string `yaml:"cert_file"`   // 客户端证书 配置后启用双向 TLS
+	KeyFile    string `yaml:"key_file"`    // 客户端私钥
+	ServerName string `yaml:"server_name"` // 覆盖证书校验的服务端名称
+	Version    string `yaml:"version"`     // 固定调用该版本的实例 无可用实例时回退全部
+
+	Discovery string   `yaml:"discovery"`  // 服务发现方式 etcd(默认) static dns
+	Addresses []string `yaml:"addresses"`  // static 方式的地址列表 如 127.0.0.1:9090
+	DnsTarget string   `yaml:"dns_target"` // dns 方式的域名与端口 如 oauth.svc.cluster.local:9090
+
+	Timeout        int               `yaml:"timeout"`         // 默认调用超时(毫秒) 默认 5000
+	MethodTimeouts map[string]int    `yaml:"method_timeouts"` // 按方法覆盖超时(毫秒) 键为方法名或 /包.服务/方法
+	Retry          GrpcRetryConfig   `yaml:"retry"`
+	Breaker        GrpcBreakerConfig `yaml:"breaker"`
+}
+
+type GrpcRetryConfig struct {
+	Methods        []string `yaml:"methods"`         // 幂等方法 格式 包.服务/方法 仅这些方法会重试
+	MaxAttempts    int      `yaml:"max_attempts"`    // 含首次调用的最大次数 默认 3
+	InitialBackoff int      `yaml:"initial_backoff"` // 初始退避(毫秒) 默认 100
+	MaxBackoff     int      `yaml:"max_backoff"`     // 最大退避(毫秒) 默认 1000
+}
+
+type GrpcBreakerConfig struct {
+	Threshold   int `yaml:"threshold"`    // 连续失败多少次后熔断 默认 5
+	OpenSeconds int `yaml:"open_seconds"` // 熔断持续时间 到期后半开探测 默认 30
+}
+
+// EndpointConfig 本实例的注册元数据 zone 同时作为调用其他服务时的就近依据
+type EndpointConfig struct {
+	Version string   `yaml:"version"` // 实例版本 默认 server.app_version
+	Zone    string   `yaml:"zone"`    // 可用区
+	Weight  int      `yaml:"weight"`  // 权重 默认 1
+	Tags    []string `yaml:"tags"`    // 自定义标签
+}
+
+// FeatureConfig 业务开关 可由 etcd 动态下发
+type FeatureConfig struct {
+	RegisterOpen   string          `yaml:"register_open"`   // off 关闭注册 含三方登录自动创建账户 默认开放
+	OauthProviders []string        `yaml:"oauth_providers"` // 启用的三方登录 如 [github] 为空时全部启用
+	Toggles        map[string]bool `yaml:"toggles"`         // 功能开关 未配置的视为关闭
+}
+
+// DynamicConfig etcd 动态配置 本文件中的值作为默认值
+type DynamicConfig struct {
+	IsOn   string `yaml:"is_on"`  // on 监听 etcd 动态配置 需配置 etcd
+	Prefix string `yaml:"prefix"` // 键前缀 默认 /config/gf-user/
+}
+
+type TracingConfig struct {
+	IsOn        string  `yaml:"is_on"`        // on 开启链路追踪
+	Exporter    string  `yaml:"exporter"`     // otlp(默认) stdout file
+	Endpoint    string  `yaml:"endpoint"`     // OTLP gRPC 地址 如 otel-collector:4317
+	Insecure    bool    `yaml:"insecure"`     // OTLP 不使用 TLS
+	FilePath    string  `yaml:"file_path"`    // file 导出的文件路径
+	SampleRatio float64 `yaml:"sample_ratio"` // 根 Span 采样率 0~1 默认 1
+}
+
+type GrpcServerConfig struct {
+	IsOn          string `yaml:"is_on"`          // on 开启 gRPC 服务
+	IPAddress     string `yaml:"ip_address"`     // 监听地址
+	Port          string `yaml:"port"`           // 监听端口
+	ServiceName   string `yaml:"service_name"`   // 注册到 etcd 的服务名 默认 gf-user-service
+	AdvertiseAddr string `yaml:"advertise_addr"` // 注册到 etcd 的地址 默认 ip_address:port
+	TlsPem        string `yaml:"tls_pem"`        // 服务端证书 未声明 insecure 时必填
+	TlsKey        string `yaml:"tls_key"`        // 服务端私钥
+	ClientCa      string `yaml:"client_ca"`      // 校验调用方证书的 CA 调用方须出示客户端证书
+	Insecure      bool   `yaml:"insecure"`       // 明确声明不使用 TLS 且不校验调用方 仅限内网调试
+}
+
+type IdpConfig struct {
+	Issuer          string `yaml:"issuer"`            // 签发者 如 https://user.gofurry.cn 配置 signing_key 时必填
+	SigningKey      string `yaml:"signing_key"`       // RS256 签名私钥路径 PKCS8 PEM
+	KeyId           string `yaml:"key_id"`            // JWKS kid
+	LoginUrl        string `yaml:"login_url"`         // 未登录时跳转的登录页
+	CodeTTL         int    `yaml:"code_ttl"`          // 授权码有效期(秒)
+	AccessTokenTTL  int    `yaml:"access_token_ttl"`  // 访问令牌有效期(秒)
+	RefreshTokenTTL int    `yaml:"refresh_token_ttl"` // 刷新令牌有效期(秒)
 }
 
 type AuthConfig struct {
-	AuthSalt  string `yaml:"auth_salt"`
-	JwtSecret string `yaml:"jwt_secret"`
+	AuthSalt      string `yaml:"auth_salt"`
+	AuthSaltFile  string `yaml:"auth_salt_file"` // 从文件读取 auth_salt
+	JwtSecret     string `yaml:"jwt_secret"`
+	JwtSecretFile string `yaml:"jwt_secret_file"` // 从文件读取 jwt_secret
+
+	Cookie   CookieConfig   `yaml:"cookie"`
+	Redirect RedirectConfig `yaml:"redirect"`
+	// 角色权限 如 admin: ["*"] 供 gRPC CheckPermission 使用
+	RolePermissions map[string][]string `yaml:"role_permissions"`
+}
+
+type CookieConfig struct {
+	Name     string `yaml:"name"`      // Cookie 名称 默认 Authorization
+	Domain   string `yaml:"domain"`    // 前端域名
+	Path     string `yaml:"path"`      // 默认全站 /
+	Secure   bool   `yaml:"secure"`    // 开发环境 false 生产环境 true
+	SameSite string `yaml:"same_site"` // Lax Strict None
+	MaxAge   int    `yaml:"max_age"`   // 有效期(秒) 默认与 JWT 一致
+}
+
+type RedirectConfig struct {
+	DefaultUrl string   `yaml:"default_url"` // 登录后默认跳转地址
+	Allowlist  []string `yaml:"allowlist"`   // 允许跳转的前端 如 https://gofurry.cn https://*.gofurry.cn
 }
 
 type EtcdConfig struct {
@@ -62,8 +174,29 @@ type WafConfig struct {
 }
 
 type MiddlewareConfig struct {
-	Swagger SwaggerConfig `yaml:"swagger"`
-	Cors    CorsConfig    `yaml:"cors"`
+	Swagger   SwaggerConfig   `yaml:"swagger"`
+	Cors      CorsConfig      `yaml:"cors"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	AccessLog AccessLogConfig `yaml:"access_log"`
+}
+
+type AccessLogConfig struct {
+	IsOn          string             `yaml:"is_on"`           // on 开启访问日志
+	SampleRate    float64            `yaml:"sample_rate"`     // 默认采样率 0~1 未配置为 1
+	RouteSamples  map[string]float64 `yaml:"route_samples"`   // 按路由模板覆盖采样率 如 /readyz: 0
+	LogHeaders    string             `yaml:"log_headers"`     // on 记录请求头 敏感头脱敏
+	LogBody       string             `yaml:"log_body"`        // on 记录请求体 密码 验证码等字段脱敏
+	MaxBodyLength int                `yaml:"max_body_length"` // 请求体最大记录字节 默认 1024
+}
+
+// MetricsConfig 指标在独立端口暴露 不经过业务端口 默认仅本机可访问
+type MetricsConfig struct {
+	IsOn   string `yaml:"is_on"`  // on 开启 Prometheus 指标
+	Path   string `yaml:"path"`   // 默认 /metrics
+	Listen string `yaml:"listen"` // 独立监听地址 默认 127.0.0.1:9464 监听非本机地址时须配置 secret
+	Secret string `yaml:"secret"` // 抓取时须携带 Authorization: Bearer <secret> 为空不校验
+	// 从文件读取 secret
+	SecretFile string `yaml:"secret_file"`
 }
 
 type CorsConfig struct {
@@ -79,15 +212,17 @@ type SwaggerConfig struct {
 }
 
 type GithubConfig struct {
-	ClientId     string `yaml:"client_id"`
-	ClientSecret string `yaml:"client_secret"`
-	RedirectUrl  string `yaml:"redirect_url"`
+	ClientId         string `yaml:"client_id"`
+	ClientSecret     string `yaml:"client_secret"`
+	ClientSecretFile string `yaml:"client_secret_file"` // 从文件读取 client_secret
+	RedirectUrl      string `yaml:"redirect_url"`
 }
 
 type GiteeConfig struct {
-	ClientId     string `yaml:"client_id"`
-	ClientSecret string `yaml:"client_secret"`
-	RedirectUrl  string `yaml:"redirect_url"`
+	ClientId         string `yaml:"client_id"`
+	ClientSecret     string `yaml:"client_secret"`
+	ClientSecretFile string `yaml:"client_secret_file"` // 从文件读取 client_secret
+	RedirectUrl      string `yaml:"redirect_url"`
 }
 
 type ThreadConfig struct {
@@ -102,11 +237,15 @@ type EmailConfig struct {
 	EmailPort     int    `yaml:"email_port"`
 	EmailUser     string `yaml:"email_user"`
 	EmailPassword string `yaml:"email_password"`
+	// 从文件读取 email_password
+	EmailPasswordFile string `yaml:"email_password_file"`
 }
 
 type RedisConfig struct {
 	RedisAddr     string `yaml:"redis_addr"`
 	RedisPassword string `yaml:"redis_password"`
+	// 从文件读取 redis_password
+	RedisPasswordFile string `yaml:"redis_password_file"`
 }
 
 type LogConfig struct {
@@ -114,14 +253,57 @@ type LogConfig struct {
 	LogPath          string `yaml:"log_path"`
 	LogLevel         string `yaml:"log_level"`
 	LogChokeLength   int    `yaml:"log_choke_length"`
+	LogFormat        string `yaml:"log_format"`        // text(默认) json
+	LogRotationTime  string `yaml:"log_rotation_time"` // daily(默认) hourly off
+	LogMaxSize       int    `yaml:"log_max_size"`      // 单文件最大 MB 0 不限制
+	LogMaxAge        int    `yaml:"log_max_age"`       // 历史文件保留天数 0 不限制
+	LogCompress      string `yaml:"log_compress"`      // on 压缩历史文件
+	LogErrorPath     string `yaml:"log_error_path"`    // error 及以上级别单独输出 默认 <log_path>-error
+	LogStdout        string `yaml:"log_stdout"`        // on 写文件时同时输出到标准输出
 }
 
 type DataBaseConfig struct {
+	Driver string `yaml:"driver"`  // postgres(默认) sqlite 本地开发与测试可用 sqlite
+	DBPath string `yaml:"db_path"` // sqlite 数据库文件 :memory: 为内存库 启动时自动迁移
+
 	DBName     string `yaml:"db_name"`
 	DBUsername string `yaml:"db_username"`
 	DBPassword string `yaml:"db_password"`
-	DBHost     string `yaml:"db_host"`
-	DBPort     string `yaml:"db_port"`
+	// 从文件读取 db_password
+	DBPasswordFile string `yaml:"db_password_file"`
+	DBHost         string `yaml:"db_host"`
+	DBPort         string `yaml:"db_port"`
+	AutoMigrate    string `yaml:"auto_migrate"` // on 启动时执行数据库迁移 默认仅提示未执行的迁移
+
+	SSLMode     string `yaml:"ssl_mode"`      // disable(默认) require verify-ca verify-full
+	SSLRootCert string `yaml:"ssl_root_cert"` // 校验服务端证书的 CA 文件 verify-ca verify-full 时必填
+	SSLCert     string `yaml:"ssl_cert"`      // 客户端证书 与 ssl_key 同时配置
+	SSLKey      string `yaml:"ssl_key"`       // 客户端私钥
+
+	MaxIdleConns     int `yaml:"max_idle_conns"`     // 最大空闲连接数 默认 100
+	MaxOpenConns     int `yaml:"max_open_conns"`     // 最大连接数 默认 1000
+	ConnMaxLifetime  int `yaml:"conn_max_lifetime"`  // 连接最长复用时间(秒) 默认 60
+	ConnMaxIdleTime  int `yaml:"conn_max_idle_time"` // 空闲连接保留时间(秒) 0 不限制
+	ConnectTimeout   int `yaml:"connect_timeout"`    // 建立连接超时(秒) 默认 5
+	StatementTimeout int `yaml:"statement_timeout"`  // 单条语句超时(毫秒) 0 不限制 仅 postgres
+	ConnectRetries   int `yaml:"connect_retries"`    // 启动时连接失败的重试次数 默认 5
+	ConnectBackoff   int `yaml:"connect_backoff"`    // 首次重试间隔(毫秒) 之后逐次翻倍 最长 30 秒 默认 1000
+
+	// 只读副本 host:port 账号 库名与连接参数同主库 批量查询用户与 OAuth 客户端查询走副本
+	Replicas []string `yaml:"replicas"`
+}
+
+// DriverName 数据库驱动 默认 postgres
+func (conf DataBaseConfig) DriverName() string {
+	if conf.Driver == "" {
+		return "postgres"
+	}
+	return strings.ToLower(conf.Driver)
+}
+
+// InMemory 是否 sqlite 内存库 进程退出后数据丢失
+func (conf DataBaseConfig) InMemory() bool {
+	return conf.DriverName() == "sqlite" && (conf.DBPath == ":memory:" || strings.HasPrefix(conf.DBPath, "file::memory:"))
 }
 
 type ServerConfig struct {
@@ -131,6 +313,11 @@ type ServerConfig struct {
 	IPAddress   string `yaml:"ip_address"`
 	Port        string `yaml:"port"`
 	MemoryLimit int    `yaml:"memory_limit"`
+
+	ServiceName   string `yaml:"service_name"`   // HTTP 服务注册到 etcd 的服务名 默认 gf-user-http
+	AdvertiseAddr string `yaml:"advertise_addr"` // 注册到 etcd 的地址 默认 ip_address:port
+
+	ShutdownTimeout int `yaml:"shutdown_timeout"` // 退出时等待请求与后台任务完成的时间(秒) 默认 30
 }
 
 type KeyConfig struct {
@@ -138,21 +325,30 @@ type KeyConfig struct {
 	LoginPublic  string `yaml:"login_public"`
 	TlsKey       string `yaml:"tls_key"`
 	TlsPem       string `yaml:"tls_pem"`
-	GrpcTls      string `yaml:"grpc_tls"`
+	GrpcTls      string `yaml:"grpc_tls"` // 未在 grpc_clients 中配置的服务默认使用的 CA 证书
 }
 
 func InitServerConfig(projectName string) {
-	InitConfig(projectName, "server.yaml", configuration)
+	// 容器部署可不提供配置文件 全部使用环境变量
+	if !hasEnvOverrides() || configFilePath(projectName, "server.yaml") != "" {
+		InitConfig(projectName, "server.yaml", configuration)
+	}
+	loadErrs = applyEnvOverrides(configuration)
+	loadErrs = append(loadErrs, resolveSecrets(configuration)...)
+	fileConfig = configuration
+	current.Store(configuration)
 }
 
 func InitConfig(projectName string, fileName string, conf interface{}) {
 	hit := false
+	var loadErr error
 
 	file := "/etc/" + projectName + "/" + fileName
 	if FileExists(file) {
 		err := loadYaml(file, conf)
 		if err != nil {
 			fmt.Println(err.Error())
+			loadErr = fmt.Errorf("%s: %w", file, err)
 		} else {
 			hit = true
 		}
@@ -169,6 +365,7 @@ func InitConfig(projectName string, fileName string, conf interface{}) {
 				err = loadYaml(filePath, conf)
 				if err != nil {
 					fmt.Println("Error loading "+fileName+" file:", err.Error())
+					loadErr = fmt.Errorf("%s: %w", filePath, err)
 				} else {
 					hit = true
 				}
@@ -177,11 +374,26 @@ func InitConfig(projectName string, fileName string, conf interface{}) {
 	}
 
 	if hit == false {
+		if loadErr != nil {
+			panic("load " + fileName + " failed: " + loadErr.Error())
+		}
 		fmt.Println("can not find any " + fileName + " file")
 		panic("can not find any " + fileName + " file")
 	}
 }
 
+// 配置文件路径 查找顺序与 InitConfig 一致 未找到时为空
+func configFilePath(projectName string, fileName string) string {
+	if file := "/etc/" + projectName + "/" + fileName; FileExists(file) {
+		return file
+	}
+	pwd, err := os.Getwd()
+	if err == nil && FileExists(pwd+"/conf/"+fileName) {
+		return pwd + "/conf/" + fileName
+	}
+	return ""
+}
+
 func getOrDefault(key string, def string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -213,6 +425,7 @@ func loadYaml(path string, conf interface{}) (err error) {
 	return errors.New("未找到配置文件" + path)
 }
 
+// GetServerConfig 当前生效的配置 热更新后返回新的快照 调用方不应修改
 func GetServerConfig() *serverConfig {
-	return configuration
+	return current.Load()
 }
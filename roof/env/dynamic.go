@@ -0,0 +1,212 @@
+package env
+
+/*
+ * @Desc: 动态配置 etcd 中的值叠加在配置文件之上
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/GoFurry/gofurry-user/common"
+	"gopkg.in/yaml.v2"
+)
+
+// 允许动态下发的配置 可下发整段或其中的字段 如 feature 或 feature.register_open
+var dynamicFields = []string{
+	"middleware.cors",
+	"feature",
+	"log.log_level",
+}
+
+var (
+	// 配置文件与环境变量的结果 热更新时替换 由 reloadMu 保护
+	fileConfig *serverConfig
+	// 当前生效的动态配置 键为 yaml 路径
+	dynamicValues map[string]string
+)
+
+// DynamicPrefix etcd 键前缀 以 / 结尾
+func DynamicPrefix() string {
+	prefix := GetServerConfig().Dynamic.Prefix
+	if prefix == "" {
+		prefix = "/config/" + common.COMMON_PROJECT_NAME + "/"
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// DynamicValues 当前生效的动态配置副本
+func DynamicValues() map[string]string {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	values := make(map[string]string, len(dynamicValues))
+	for key, value := range dynamicValues {
+		values[key] = value
+	}
+	return values
+}
+
+// ApplyDynamicConfig 以配置文件为默认值 叠加 etcd 中的全部动态配置
+// values 键为 yaml 路径 未允许或格式错误的键跳过并在 skipped 中返回 合并后校验失败时整体拒绝
+func ApplyDynamicConfig(values map[string]string) (applied []ConfigChange, skipped []error, err error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next, accepted, skipped := overlayDynamic(fileConfig, values)
+	if err = next.validate(nil); err != nil {
+		return nil, skipped, err
+	}
+	dynamicValues = accepted
+	return storeConfig(next), skipped, nil
+}
+
+// storeConfig 替换生效配置并调用回调 返回变更项 调用方持有 reloadMu
+func storeConfig(next *serverConfig) []ConfigChange {
+	applied := diffConfig("", reflect.ValueOf(*GetServerConfig()), reflect.ValueOf(*next))
+	if len(applied) == 0 {
+		return nil
+	}
+	current.Store(next)
+	for _, hook := range reloadHooks {
+		hook()
+	}
+	return applied
+}
+
+// overlayDynamic 在 base 的副本上逐项应用动态配置 按路径排序 整段先于其中的字段
+func overlayDynamic(base *serverConfig, values map[string]string) (*serverConfig, map[string]string, []error) {
+	next := *base
+	accepted := make(map[string]string, len(values))
+	var errs []error
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !dynamicAllowed(key) {
+			errs = append(errs, fmt.Errorf("%s: 不支持动态配置", key))
+			continue
+		}
+		// 单项失败不影响其余配置
+		item := next
+		if err := setPath(reflect.ValueOf(&item).Elem(), strings.Split(key, "."), values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", key, err))
+			continue
+		}
+		next = item
+		accepted[key] = values[key]
+	}
+	return &next, accepted, errs
+}
+
+func dynamicAllowed(key string) bool {
+	if isSecretField(key) {
+		return false
+	}
+	for _, field := range dynamicFields {
+		if key == field || strings.HasPrefix(key, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// setPath 按 yaml 路径写入值 值按 YAML 解析 map 先复制再修改 不影响配置文件中的原值
+func setPath(v reflect.Value, path []string, raw string) error {
+	if len(path) == 0 {
+		ptr := reflect.New(v.Type())
+		if err := yaml.UnmarshalStrict([]byte(raw), ptr.Interface()); err != nil {
+			return fmt.Errorf("格式错误 %v", err)
+		}
+		v.Set(ptr.Elem())
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		field := fieldByYaml(v, path[0])
+		if !field.IsValid() {
+			return errors.New("未知配置项 " + path[0])
+		}
+		return setPath(field, path[1:], raw)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len()+1)
+		for _, key := range v.MapKeys() {
+			copied.SetMapIndex(key, v.MapIndex(key))
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		item := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			item.Set(existing)
+		}
+		if err := setPath(item, path[1:], raw); err != nil {
+			return err
+		}
+		copied.SetMapIndex(key, item)
+		v.Set(copied)
+		return nil
+	}
+	return errors.New("未知配置项 " + strings.Join(path, "."))
+}
+
+// MaskedMap 敏感字段已脱敏的配置 键为 yaml 名称 用于接口输出
+func (conf serverConfig) MaskedMap() map[string]any {
+	masked := conf.Masked()
+	return toMap(reflect.ValueOf(masked)).(map[string]any)
+}
+
+func toMap(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Struct:
+		out := make(map[string]any)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if tag := yamlName(t.Field(i)); tag != "" {
+				out[tag] = toMap(v.Field(i))
+			}
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]any, v.Len())
+		for _, key := range v.MapKeys() {
+			out[fmt.Sprint(key.Interface())] = toMap(v.MapIndex(key))
+		}
+		return out
+	}
+	return v.Interface()
+}
+
+// RegisterEnabled 是否开放注册
+func (conf FeatureConfig) RegisterEnabled() bool {
+	return conf.RegisterOpen != "off"
+}
+
+// OauthEnabled 是否启用该三方登录
+func (conf FeatureConfig) OauthEnabled(provider string) bool {
+	if len(conf.OauthProviders) == 0 {
+		return true
+	}
+	for _, item := range conf.OauthProviders {
+		if strings.EqualFold(item, provider) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled 功能开关是否开启
+func (conf FeatureConfig) Enabled(name string) bool {
+	return conf.Toggles[name]
+}
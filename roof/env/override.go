@@ -0,0 +1,139 @@
+package env
+
+/*
+ * @Desc: 环境变量覆盖配置 GF_USER_ + yaml 路径 如 GF_USER_AUTH_JWT_SECRET
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const ENV_PREFIX = "GF_USER_"
+
+// applyEnvOverrides 按 yaml 标签逐字段查找环境变量
+// 标量直接解析 []string 支持逗号分隔 其余切片与 map 按 YAML/JSON 解析
+// map 的值为结构体时 已在文件中声明的键可逐字段覆盖 如 GF_USER_GRPC_CLIENTS_GITHUB_OAUTH_SERVICE_TIMEOUT
+// 返回格式错误的环境变量
+func applyEnvOverrides(conf any) []error {
+	var errs []error
+	applyEnvValue(reflect.ValueOf(conf).Elem(), strings.TrimSuffix(ENV_PREFIX, "_"), &errs)
+	return errs
+}
+
+func applyEnvValue(v reflect.Value, name string, errs *[]error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if !field.IsExported() || tag == "" || tag == "-" {
+				continue
+			}
+			applyEnvValue(v.Field(i), name+"_"+envName(tag), errs)
+		}
+		return
+	case reflect.Map:
+		if raw, ok := os.LookupEnv(name); ok {
+			setFromYaml(v, name, raw, errs)
+		}
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return
+		}
+		for _, key := range v.MapKeys() {
+			item := reflect.New(v.Type().Elem()).Elem()
+			item.Set(v.MapIndex(key))
+			applyEnvValue(item, name+"_"+envName(key.String()), errs)
+			v.SetMapIndex(key, item)
+		}
+		return
+	}
+
+	raw, ok := os.LookupEnv(name)
+	if !ok {
+		return
+	}
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(raw); err == nil {
+			v.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(raw, 10, v.Type().Bits()); err == nil {
+			v.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if n, err = strconv.ParseUint(raw, 10, v.Type().Bits()); err == nil {
+			v.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(raw, v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(raw), "[") {
+			items := reflect.MakeSlice(v.Type(), 0, 0)
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = reflect.Append(items, reflect.ValueOf(item))
+				}
+			}
+			v.Set(items)
+			return
+		}
+		setFromYaml(v, name, raw, errs)
+		return
+	default:
+		return
+	}
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("环境变量 %s 格式错误: %v", name, err))
+	}
+}
+
+func setFromYaml(v reflect.Value, name string, raw string, errs *[]error) {
+	ptr := reflect.New(v.Type())
+	if err := yaml.Unmarshal([]byte(raw), ptr.Interface()); err != nil {
+		*errs = append(*errs, fmt.Errorf("环境变量 %s 格式错误: %v", name, err))
+		return
+	}
+	v.Set(ptr.Elem())
+}
+
+// yaml 键转环境变量名 github-oauth-service -> GITHUB_OAUTH_SERVICE
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// 是否设置了任意 GF_USER_ 环境变量 无配置文件时可仅用环境变量启动
+func hasEnvOverrides() bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, ENV_PREFIX) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package env
+
+/*
+ * @Desc: 配置热更新 仅替换运行期可安全变更的字段
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/GoFurry/gofurry-user/common"
+)
+
+var (
+	current atomic.Pointer[serverConfig]
+	// 加载时的环境变量与密钥错误 由 Validate 统一报告
+	loadErrs []error
+
+	reloadMu    sync.Mutex
+	reloadHooks []func()
+)
+
+// ConfigChange 一项配置变更 敏感字段的值已脱敏
+type ConfigChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+func (c ConfigChange) String() string {
+	return c.Field + ": " + c.Old + " -> " + c.New
+}
+
+// OnReload 注册热更新回调 在新配置生效后调用
+func OnReload(fn func()) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloadHooks = append(reloadHooks, fn)
+}
+
+// ConfigFile 当前使用的配置文件 仅使用环境变量时为空
+func ConfigFile() string {
+	return configFilePath(common.COMMON_PROJECT_NAME, "server.yaml")
+}
+
+// ReloadServerConfig 重新读取配置文件与环境变量
+// 新配置校验失败时不做任何变更 applied 为已生效的变更 ignored 为需重启才生效的字段
+func ReloadServerConfig() (applied []ConfigChange, ignored []string, err error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next := new(serverConfig)
+	if path := ConfigFile(); path != "" {
+		if err = loadYaml(path, next); err != nil {
+			return nil, nil, fmt.Errorf("读取配置文件失败 %s: %w", path, err)
+		}
+	} else if !hasEnvOverrides() {
+		return nil, nil, errors.New("未找到配置文件")
+	}
+	errs := applyEnvOverrides(next)
+	errs = append(errs, resolveSecrets(next)...)
+	if err = next.validate(errs); err != nil {
+		return nil, nil, err
+	}
+
+	// 仅替换可热更新的字段 动态配置重新叠加在新的文件配置之上
+	merged := *fileConfig
+	merged.Log.LogLevel = next.Log.LogLevel
+	merged.Middleware.Cors = next.Middleware.Cors
+	merged.Waf = next.Waf
+	merged.Email = next.Email
+	merged.Github = next.Github
+	merged.Gitee = next.Gitee
+	merged.Feature = next.Feature
+
+	for _, change := range diffConfig("", reflect.ValueOf(merged), reflect.ValueOf(*next)) {
+		ignored = append(ignored, change.Field)
+	}
+	effective, accepted, _ := overlayDynamic(&merged, dynamicValues)
+	if err = effective.validate(nil); err != nil {
+		return nil, nil, err
+	}
+	fileConfig = &merged
+	dynamicValues = accepted
+	applied = storeConfig(effective)
+	return applied, ignored, nil
+}
+
+// diffConfig 按 yaml 路径比较两份配置
+func diffConfig(prefix string, before reflect.Value, after reflect.Value) []ConfigChange {
+	if before.Kind() != reflect.Struct {
+		if reflect.DeepEqual(before.Interface(), after.Interface()) {
+			return nil
+		}
+		if isSecretField(prefix) {
+			return []ConfigChange{{Field: prefix, Old: maskValue(before), New: maskValue(after)}}
+		}
+		return []ConfigChange{{Field: prefix, Old: fmt.Sprint(before.Interface()), New: fmt.Sprint(after.Interface())}}
+	}
+	var changes []ConfigChange
+	t := before.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if !t.Field(i).IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+		name := tag
+		if prefix != "" {
+			name = prefix + "." + tag
+		}
+		changes = append(changes, diffConfig(name, before.Field(i), after.Field(i))...)
+	}
+	return changes
+}
+
+func maskValue(v reflect.Value) string {
+	if v.IsZero() {
+		return `""`
+	}
+	return maskedText
+}
@@ -0,0 +1,243 @@
+package env
+
+/*
+ * @Desc: 密钥配置 *_file 从挂载文件读取 enc: 前缀的值启动时用主密钥解密
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ENC_PREFIX      = "enc:"
+	MASTER_KEY_ENV  = "GF_USER_MASTER_KEY"      // 主密钥
+	MASTER_KEY_FILE = "GF_USER_MASTER_KEY_FILE" // 主密钥文件 优先于 GF_USER_MASTER_KEY
+
+	fileSuffix = "_file"
+	maskedText = "***"
+)
+
+// resolveSecrets 读取 *_file 指向的文件并解密 enc: 值 返回全部错误
+func resolveSecrets(conf any) []error {
+	var errs []error
+	resolveFiles(reflect.ValueOf(conf).Elem(), "", &errs)
+
+	var gcm cipher.AEAD
+	walkStrings(reflect.ValueOf(conf).Elem(), "", func(field string, v reflect.Value) {
+		if !strings.HasPrefix(v.String(), ENC_PREFIX) {
+			return
+		}
+		if gcm == nil {
+			var err error
+			if gcm, err = masterCipher(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", field, err))
+				return
+			}
+		}
+		plain, err := decrypt(gcm, v.String())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: 解密失败 %v", field, err))
+			return
+		}
+		v.SetString(plain)
+	})
+	return errs
+}
+
+// resolveFiles 字段 xxx_file 非空时读取文件内容写入同级的 xxx 字段
+func resolveFiles(v reflect.Value, prefix string, errs *[]error) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := yamlName(t.Field(i))
+		if tag == "" {
+			continue
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			resolveFiles(field, joinField(prefix, tag), errs)
+			continue
+		}
+		if field.Kind() != reflect.String || !strings.HasSuffix(tag, fileSuffix) || field.String() == "" {
+			continue
+		}
+		target := fieldByYaml(v, strings.TrimSuffix(tag, fileSuffix))
+		if !target.IsValid() {
+			continue
+		}
+		name := joinField(prefix, strings.TrimSuffix(tag, fileSuffix))
+		if target.String() != "" {
+			*errs = append(*errs, fmt.Errorf("%s: 与 %s%s 不能同时配置", name, name, fileSuffix))
+			continue
+		}
+		content, err := os.ReadFile(field.String())
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s%s: 读取失败 %v", name, fileSuffix, err))
+			continue
+		}
+		// 挂载文件通常带换行
+		target.SetString(strings.TrimRight(string(content), "\r\n"))
+	}
+}
+
+// walkStrings 遍历结构体中的字符串字段
+func walkStrings(v reflect.Value, prefix string, fn func(field string, v reflect.Value)) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := yamlName(t.Field(i))
+		if tag == "" {
+			continue
+		}
+		switch field := v.Field(i); field.Kind() {
+		case reflect.Struct:
+			walkStrings(field, joinField(prefix, tag), fn)
+		case reflect.String:
+			fn(joinField(prefix, tag), field)
+		}
+	}
+}
+
+// 主密钥经 SHA-256 派生为 AES-256 密钥
+func masterCipher() (cipher.AEAD, error) {
+	key := os.Getenv(MASTER_KEY_ENV)
+	if path := os.Getenv(MASTER_KEY_FILE); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取主密钥文件失败 %v", err)
+		}
+		key = strings.TrimRight(string(content), "\r\n")
+	}
+	if key == "" {
+		return nil, errors.New("存在 enc: 加密值 但未设置 " + MASTER_KEY_ENV + " 或 " + MASTER_KEY_FILE)
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// enc:base64(nonce + 密文)
+func decrypt(gcm cipher.AEAD, value string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, ENC_PREFIX))
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return "", errors.New("密文长度不足")
+	}
+	plain, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("主密钥不匹配或密文已损坏")
+	}
+	return string(plain), nil
+}
+
+// EncryptSecret 用主密钥加密 输出可直接写入配置的 enc: 值
+func EncryptSecret(plain string) (string, error) {
+	gcm, err := masterCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return ENC_PREFIX + base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+// isSecretField 密码 密钥 盐等字段不输出明文 *_file 为路径不做处理
+func isSecretField(field string) bool {
+	name := field[strings.LastIndex(field, ".")+1:]
+	if strings.HasSuffix(name, fileSuffix) {
+		return false
+	}
+	for _, word := range []string{"password", "secret", "salt"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSecrets 将敏感字段替换为 *** 空值保持为空 便于看出是否已配置
+func maskSecrets(v reflect.Value) {
+	walkStrings(v, "", func(field string, s reflect.Value) {
+		if s.String() != "" && isSecretField(field) {
+			s.SetString(maskedText)
+		}
+	})
+}
+
+// Masked 敏感字段已脱敏的配置副本
+func (conf serverConfig) Masked() serverConfig {
+	maskSecrets(reflect.ValueOf(&conf).Elem())
+	return conf
+}
+
+// String 打印配置时始终脱敏
+func (conf serverConfig) String() string {
+	return maskedString(conf)
+}
+
+func (c DataBaseConfig) String() string { return maskedString(c) }
+func (c EmailConfig) String() string    { return maskedString(c) }
+func (c RedisConfig) String() string    { return maskedString(c) }
+func (c AuthConfig) String() string     { return maskedString(c) }
+func (c GithubConfig) String() string   { return maskedString(c) }
+func (c GiteeConfig) String() string    { return maskedString(c) }
+
+func maskedString(value any) string {
+	copied := reflect.New(reflect.TypeOf(value)).Elem()
+	copied.Set(reflect.ValueOf(value))
+	maskSecrets(copied)
+	out, err := yaml.Marshal(copied.Interface())
+	if err != nil {
+		return "{" + err.Error() + "}"
+	}
+	return string(out)
+}
+
+func yamlName(field reflect.StructField) string {
+	tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if !field.IsExported() || tag == "-" {
+		return ""
+	}
+	return tag
+}
+
+func fieldByYaml(v reflect.Value, tag string) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if yamlName(t.Field(i)) == tag {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+func joinField(prefix string, tag string) string {
+	if prefix == "" {
+		return tag
+	}
+	return prefix + "." + tag
+}
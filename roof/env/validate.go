@@ -0,0 +1,317 @@
+package env
+
+/*
+ * @Desc: 启动时的配置校验 一次列出全部问题
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/GoFurry/gofurry-user/common"
+)
+
+// 校验结果收集
+type checker struct {
+	errs []error
+}
+
+func (c *checker) fail(field string, format string, args ...any) {
+	c.errs = append(c.errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+// required 必填项
+func (c *checker) required(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		c.fail(field, "不能为空")
+	}
+}
+
+// port 端口 1-65535 allowEmpty 为 true 时可不填
+func (c *checker) port(field string, value string, allowEmpty bool) {
+	if value == "" {
+		if !allowEmpty {
+			c.fail(field, "不能为空")
+		}
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 65535 {
+		c.fail(field, "端口无效 %q 应为 1-65535", value)
+	}
+}
+
+// hostPort host:port 形式的地址
+func (c *checker) hostPort(field string, value string) {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		c.fail(field, "地址无效 %q 应为 host:port", value)
+		return
+	}
+	c.port(field, port, false)
+}
+
+// file 文件必须存在 未配置时跳过
+func (c *checker) file(field string, path string) {
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		c.fail(field, "文件不存在或不可读 %s", path)
+		return
+	}
+	if info.IsDir() {
+		c.fail(field, "应为文件而不是目录 %s", path)
+	}
+}
+
+// dir 文件所在目录必须存在 用于运行时创建的文件
+func (c *checker) dir(field string, path string) {
+	if path == "" {
+		return
+	}
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		c.fail(field, "所在目录不存在 %s", filepath.Dir(path))
+	}
+}
+
+// oneOf 枚举值 空值视为默认
+func (c *checker) oneOf(field string, value string, options ...string) {
+	if value == "" {
+		return
+	}
+	for _, option := range options {
+		if strings.EqualFold(value, option) {
+			return
+		}
+	}
+	c.fail(field, "取值无效 %q 可选 %s", value, strings.Join(options, " "))
+}
+
+// switchValue on off 开关
+func (c *checker) switchValue(field string, value string) {
+	c.oneOf(field, value, "on", "off")
+}
+
+// ratio 0~1 的比例
+func (c *checker) ratio(field string, value float64) {
+	if value < 0 || value > 1 {
+		c.fail(field, "取值无效 %v 应在 0~1 之间", value)
+	}
+}
+
+// nonNegative 非负整数
+func (c *checker) nonNegative(field string, value int) {
+	if value < 0 {
+		c.fail(field, "不能为负数 %d", value)
+	}
+}
+
+// Validate 校验当前配置 返回所有问题 每行一条
+func Validate() error {
+	return GetServerConfig().validate(loadErrs)
+}
+
+// loadErrs 为加载时环境变量格式与密钥读取的错误
+func (conf *serverConfig) validate(loadErrs []error) error {
+	c := &checker{errs: append([]error(nil), loadErrs...)}
+
+	// 服务
+	c.port("server.port", conf.Server.Port, false)
+	c.nonNegative("server.memory_limit", conf.Server.MemoryLimit)
+	c.nonNegative("server.shutdown_timeout", conf.Server.ShutdownTimeout)
+
+	// 密钥
+	c.required("auth.jwt_secret", conf.Auth.JwtSecret)
+	c.required("auth.auth_salt", conf.Auth.AuthSalt)
+	c.oneOf("auth.cookie.same_site", conf.Auth.Cookie.SameSite, "Lax", "Strict", "None")
+	c.nonNegative("auth.cookie.max_age", conf.Auth.Cookie.MaxAge)
+
+	// 数据库与缓存
+	c.oneOf("database.driver", conf.DataBase.Driver, "postgres", "sqlite")
+	c.switchValue("database.auto_migrate", conf.DataBase.AutoMigrate)
+	c.nonNegative("database.max_idle_conns", conf.DataBase.MaxIdleConns)
+	c.nonNegative("database.max_open_conns", conf.DataBase.MaxOpenConns)
+	c.nonNegative("database.conn_max_lifetime", conf.DataBase.ConnMaxLifetime)
+	c.nonNegative("database.conn_max_idle_time", conf.DataBase.ConnMaxIdleTime)
+	c.nonNegative("database.connect_timeout", conf.DataBase.ConnectTimeout)
+	c.nonNegative("database.statement_timeout", conf.DataBase.StatementTimeout)
+	c.nonNegative("database.connect_retries", conf.DataBase.ConnectRetries)
+	c.nonNegative("database.connect_backoff", conf.DataBase.ConnectBackoff)
+	if conf.DataBase.DriverName() == "sqlite" {
+		c.required("database.db_path", conf.DataBase.DBPath)
+		if !conf.DataBase.InMemory() {
+			c.dir("database.db_path", conf.DataBase.DBPath)
+		}
+		if len(conf.DataBase.Replicas) > 0 {
+			c.fail("database.replicas", "sqlite 不支持只读副本")
+		}
+	} else {
+		c.required("database.db_host", conf.DataBase.DBHost)
+		c.port("database.db_port", conf.DataBase.DBPort, false)
+		c.required("database.db_name", conf.DataBase.DBName)
+		c.required("database.db_username", conf.DataBase.DBUsername)
+		c.oneOf("database.ssl_mode", conf.DataBase.SSLMode, "disable", "require", "verify-ca", "verify-full")
+		if strings.HasPrefix(strings.ToLower(conf.DataBase.SSLMode), "verify-") {
+			c.required("database.ssl_root_cert", conf.DataBase.SSLRootCert)
+		}
+		c.file("database.ssl_root_cert", conf.DataBase.SSLRootCert)
+		if (conf.DataBase.SSLCert == "") != (conf.DataBase.SSLKey == "") {
+			c.fail("database.ssl_cert", "须与 ssl_key 同时配置")
+		}
+		c.file("database.ssl_cert", conf.DataBase.SSLCert)
+		c.file("database.ssl_key", conf.DataBase.SSLKey)
+		for i, addr := range conf.DataBase.Replicas {
+			c.hostPort(fmt.Sprintf("database.replicas[%d]", i), addr)
+		}
+	}
+	if conf.Redis.RedisAddr == "" {
+		c.fail("redis.redis_addr", "不能为空")
+	} else {
+		c.hostPort("redis.redis_addr", conf.Redis.RedisAddr)
+	}
+	if conf.Etcd.EtcdHost != "" {
+		c.port("etcd.etcd_port", conf.Etcd.EtcdPort, false)
+	}
+
+	// 邮件
+	if conf.Email.EmailHost != "" {
+		if conf.Email.EmailPort < 1 || conf.Email.EmailPort > 65535 {
+			c.fail("email.email_port", "端口无效 %d 应为 1-65535", conf.Email.EmailPort)
+		}
+		c.required("email.email_user", conf.Email.EmailUser)
+		c.required("email.email_password", conf.Email.EmailPassword)
+	}
+
+	// 三方登录 配置了 client_id 时密钥必填
+	if conf.Github.ClientId != "" {
+		c.required("github.client_secret", conf.Github.ClientSecret)
+	}
+	if conf.Gitee.ClientId != "" {
+		c.required("gitee.client_secret", conf.Gitee.ClientSecret)
+	}
+	c.file("idp.signing_key", conf.Idp.SigningKey)
+	// 令牌与发现文档中的 iss 只取配置
+	if conf.Idp.SigningKey != "" {
+		c.required("idp.issuer", conf.Idp.Issuer)
+	}
+	c.nonNegative("idp.code_ttl", conf.Idp.CodeTTL)
+	c.nonNegative("idp.access_token_ttl", conf.Idp.AccessTokenTTL)
+	c.nonNegative("idp.refresh_token_ttl", conf.Idp.RefreshTokenTTL)
+
+	// 证书
+	c.file("key.grpc_tls", conf.Key.GrpcTls)
+
+	// 中间件
+	c.switchValue("waf.waf_switch", conf.Waf.WafSwitch)
+	if conf.Waf.WafSwitch == "on" {
+		if conf.Waf.ConfPath == "" && os.Getenv("DIRECTIVES_FILE") == "" {
+			c.fail("waf.conf_path", "开启 WAF 时不能为空")
+		}
+		c.file("waf.conf_path", conf.Waf.ConfPath)
+		c.file("DIRECTIVES_FILE", os.Getenv("DIRECTIVES_FILE"))
+	}
+	c.switchValue("middleware.swagger.is_on", conf.Middleware.Swagger.IsOn)
+	if conf.Middleware.Swagger.IsOn == "on" {
+		c.file("middleware.swagger.file_path", conf.Middleware.Swagger.FilePath)
+	}
+	c.switchValue("middleware.metrics.is_on", conf.Middleware.Metrics.IsOn)
+	if listen := conf.Middleware.Metrics.Listen; listen != "" {
+		c.hostPort("middleware.metrics.listen", listen)
+		// 指标包含各接口流量与登录结果 对外监听时必须鉴权
+		if host, _, err := net.SplitHostPort(listen); err == nil && conf.Middleware.Metrics.Secret == "" {
+			if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+				c.fail("middleware.metrics.secret", "监听非本机地址 %s 时不能为空", listen)
+			}
+		}
+	}
+	accessLog := conf.Middleware.AccessLog
+	c.switchValue("middleware.access_log.is_on", accessLog.IsOn)
+	c.ratio("middleware.access_log.sample_rate", accessLog.SampleRate)
+	for route, rate := range accessLog.RouteSamples {
+		c.ratio("middleware.access_log.route_samples."+route, rate)
+	}
+
+	// 日志
+	c.oneOf("log.log_level", conf.Log.LogLevel, "trace", "debug", "info", "warn", "warning", "error", "fatal", "panic")
+	c.oneOf("log.log_format", conf.Log.LogFormat, "text", "json")
+	c.oneOf("log.log_rotation_time", conf.Log.LogRotationTime, "daily", "hourly", "off")
+	c.nonNegative("log.log_rotation_count", conf.Log.LogRotationCount)
+	c.nonNegative("log.log_max_size", conf.Log.LogMaxSize)
+	c.nonNegative("log.log_max_age", conf.Log.LogMaxAge)
+	c.nonNegative("log.log_choke_length", conf.Log.LogChokeLength)
+
+	// 链路追踪
+	c.switchValue("tracing.is_on", conf.Tracing.IsOn)
+	c.ratio("tracing.sample_ratio", conf.Tracing.SampleRatio)
+	if conf.Tracing.IsOn == "on" {
+		c.oneOf("tracing.exporter", conf.Tracing.Exporter, "otlp", "stdout", "file")
+		switch conf.Tracing.Exporter {
+		case "", "otlp":
+			c.required("tracing.endpoint", conf.Tracing.Endpoint)
+		case "file":
+			c.required("tracing.file_path", conf.Tracing.FilePath)
+			c.dir("tracing.file_path", conf.Tracing.FilePath)
+		}
+	}
+
+	// gRPC
+	c.switchValue("grpc_server.is_on", conf.GrpcServer.IsOn)
+	if conf.GrpcServer.IsOn == "on" {
+		c.port("grpc_server.port", conf.GrpcServer.Port, false)
+		// 对外提供用户资料 未明确声明 insecure 时须启用双向 TLS
+		if !conf.GrpcServer.Insecure {
+			c.required("grpc_server.tls_pem", conf.GrpcServer.TlsPem)
+			c.required("grpc_server.tls_key", conf.GrpcServer.TlsKey)
+			c.required("grpc_server.client_ca", conf.GrpcServer.ClientCa)
+		}
+		c.file("grpc_server.tls_pem", conf.GrpcServer.TlsPem)
+		c.file("grpc_server.tls_key", conf.GrpcServer.TlsKey)
+		c.file("grpc_server.client_ca", conf.GrpcServer.ClientCa)
+	}
+	for name, client := range conf.GrpcClients {
+		field := "grpc_clients." + name
+		c.file(field+".ca_file", client.CaFile)
+		c.file(field+".cert_file", client.CertFile)
+		c.file(field+".key_file", client.KeyFile)
+		if (client.CertFile == "") != (client.KeyFile == "") {
+			c.fail(field+".cert_file", "cert_file 与 key_file 需同时配置")
+		}
+		c.oneOf(field+".discovery", client.Discovery, common.DISCOVERY_ETCD, common.DISCOVERY_STATIC, common.DISCOVERY_DNS)
+		switch client.Discovery {
+		case common.DISCOVERY_STATIC:
+			if len(client.Addresses) == 0 {
+				c.fail(field+".addresses", "static 方式不能为空")
+			}
+			for _, addr := range client.Addresses {
+				c.hostPort(field+".addresses", addr)
+			}
+		case common.DISCOVERY_DNS:
+			c.required(field+".dns_target", client.DnsTarget)
+		}
+		c.nonNegative(field+".timeout", client.Timeout)
+	}
+	// 业务开关与动态配置
+	c.switchValue("feature.register_open", conf.Feature.RegisterOpen)
+	for _, provider := range conf.Feature.OauthProviders {
+		c.oneOf("feature.oauth_providers", provider, "github", "gitee")
+	}
+	c.switchValue("dynamic.is_on", conf.Dynamic.IsOn)
+	if conf.Dynamic.IsOn == "on" && conf.Etcd.EtcdHost == "" {
+		c.fail("dynamic.is_on", "开启动态配置时需配置 etcd.etcd_host")
+	}
+
+	if conf.Endpoint.Weight < 0 {
+		c.fail("endpoint.weight", "不能为负数 %d", conf.Endpoint.Weight)
+	}
+
+	return errors.Join(c.errs...)
+}
@@ -0,0 +1,116 @@
+package routers
+
+/*
+ * @Desc: gRPC 服务
+ * @author: 福狼
+ * @version: v1.0.0
+ */
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/GoFurry/gofurry-user/apps/proto/userservice"
+	"github.com/GoFurry/gofurry-user/apps/user/rpc"
+	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/roof/env"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+var GrpcServer *grpcServer
+
+type grpcServer struct {
+	health *health.Server
+}
+
+func init() {
+	GrpcServer = &grpcServer{health: health.NewServer()}
+}
+
+// Init 创建 gRPC 服务并注册用户服务 健康检查 调试模式下注册反射
+func (gs *grpcServer) Init() (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+	conf := env.GetServerConfig().GrpcServer
+	if conf.Insecure {
+		log.Warn("gRPC 服务未启用 TLS 且不校验调用方 仅限内网调试")
+	} else {
+		creds, err := serverCredentials(conf)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	// 链路追踪 从 metadata 中恢复调用方的 traceparent
+	opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	server := grpc.NewServer(opts...)
+	userservice.RegisterUserServiceServer(server, rpc.UserRpc)
+	healthpb.RegisterHealthServer(server, gs.health)
+	if env.GetServerConfig().Server.Mode == "debug" {
+		reflection.Register(server)
+	}
+
+	gs.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	gs.health.SetServingStatus(userservice.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return server, nil
+}
+
+// serverCredentials 双向 TLS 调用方须出示由 client_ca 签发的证书
+func serverCredentials(conf env.GrpcServerConfig) (credentials.TransportCredentials, error) {
+	if conf.TlsPem == "" || conf.TlsKey == "" || conf.ClientCa == "" {
+		return nil, errors.New("gRPC 服务须配置 tls_pem tls_key 与 client_ca 或明确声明 insecure")
+	}
+	cert, err := tls.LoadX509KeyPair(conf.TlsPem, conf.TlsKey)
+	if err != nil {
+		return nil, fmt.Errorf("加载服务端证书失败: %w", err)
+	}
+	caPem, err := os.ReadFile(conf.ClientCa)
+	if err != nil {
+		return nil, fmt.Errorf("读取调用方CA证书失败: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPem) {
+		return nil, fmt.Errorf("解析调用方CA证书失败: %s", conf.ClientCa)
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// Shutdown 将健康状态置为不可用 通知调用方停止路由
+func (gs *grpcServer) Shutdown() {
+	gs.health.Shutdown()
+}
+
+// ServiceName 注册到 etcd 的服务名
+func (gs *grpcServer) ServiceName() string {
+	if name := env.GetServerConfig().GrpcServer.ServiceName; name != "" {
+		return name
+	}
+	return "gf-user-service"
+}
+
+// ListenAddr 监听地址
+func (gs *grpcServer) ListenAddr() string {
+	conf := env.GetServerConfig().GrpcServer
+	return conf.IPAddress + ":" + conf.Port
+}
+
+// AdvertiseAddr 注册到 etcd 的地址
+func (gs *grpcServer) AdvertiseAddr() string {
+	if addr := env.GetServerConfig().GrpcServer.AdvertiseAddr; addr != "" {
+		return addr
+	}
+	return gs.ListenAddr()
+}
@@ -7,14 +7,19 @@ package routers
  */
 
 import (
+	"runtime/debug"
 	"sync"
 
+	idp "github.com/GoFurry/gofurry-user/apps/idp/controller"
+	health "github.com/GoFurry/gofurry-user/apps/util/health/controller"
 	"github.com/GoFurry/gofurry-user/common"
+	"github.com/GoFurry/gofurry-user/common/log"
+	"github.com/GoFurry/gofurry-user/common/metrics"
 	"github.com/GoFurry/gofurry-user/middleware"
 	"github.com/GoFurry/gofurry-user/roof/env"
+	"github.com/gofiber/contrib/otelfiber/v2"
 	"github.com/gofiber/contrib/swagger"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/pprof"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
@@ -33,6 +38,50 @@ func init() {
 
 var once = sync.Once{}
 
+// ServiceName HTTP 服务注册到 etcd 的服务名
+func (router *router) ServiceName() string {
+	if name := env.GetServerConfig().Server.ServiceName; name != "" {
+		return name
+	}
+	return "gf-user-http"
+}
+
+// ListenAddr 监听地址
+func (router *router) ListenAddr() string {
+	conf := env.GetServerConfig().Server
+	return conf.IPAddress + ":" + conf.Port
+}
+
+// MetricsListenAddr 指标监听地址 默认仅本机
+func (router *router) MetricsListenAddr() string {
+	if addr := env.GetServerConfig().Middleware.Metrics.Listen; addr != "" {
+		return addr
+	}
+	return "127.0.0.1:9464"
+}
+
+// MetricsApp 指标服务 与业务端口分开 避免对公网暴露接口流量与登录统计
+func (router *router) MetricsApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		AppName:               common.COMMON_PROJECT_NAME + "-metrics",
+		DisableStartupMessage: true,
+	})
+	path := env.GetServerConfig().Middleware.Metrics.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	app.Get(path, middleware.MetricsAuth(), metrics.Handler())
+	return app
+}
+
+// AdvertiseAddr 注册到 etcd 的地址
+func (router *router) AdvertiseAddr() string {
+	if addr := env.GetServerConfig().Server.AdvertiseAddr; addr != "" {
+		return addr
+	}
+	return router.ListenAddr()
+}
+
 func (router *router) Init() *fiber.App {
 	once.Do(func() {
 	})
@@ -61,6 +110,21 @@ func (router *router) Init() *fiber.App {
 		EnableTrustedProxyCheck: true, // 信任 Nginx 反向代理
 	})
 
+	// 请求 ID 最先生成 响应头与后续日志均可携带
+	app.Use(middleware.RequestId())
+	// 健康检查 位于其余中间件之前 不受 WAF 与跨域影响 也不计入请求指标
+	app.Get("/healthz", health.HealthApi.Healthz)
+	app.Get("/readyz", health.HealthApi.Readyz)
+	if env.GetServerConfig().Middleware.AccessLog.IsOn == "on" {
+		app.Use(middleware.AccessLog())
+	}
+	// 指标接口由 MetricsApp 在独立端口提供
+	if env.GetServerConfig().Middleware.Metrics.IsOn == "on" {
+		app.Use(middleware.Metrics())
+	}
+	// 链路追踪 Span 名称为 方法 + 路由模板
+	app.Use(otelfiber.Middleware())
+
 	cfg := swagger.Config{
 		BasePath: env.GetServerConfig().Middleware.Swagger.BasePath,
 		FilePath: env.GetServerConfig().Middleware.Swagger.FilePath,
@@ -75,24 +139,24 @@ func (router *router) Init() *fiber.App {
 	if env.GetServerConfig().Server.Mode == "debug" {
 		app.Use(pprof.New())
 	}
-	// 跨域
-	app.Use(cors.New())
-	//app.Use(cors.New(cors.Config{
-	//	AllowOrigins:     env.GetServerConfig().Middleware.Cors.AllowOrigins,
-	//	AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-	//	AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
-	//	AllowCredentials: true,
-	//}))
-	// 恢复
-	app.Use(recover.New())
-	if env.GetServerConfig().Waf.WafSwitch == "on" {
-		app.Use(middleware.CorazaMiddleware()) // CorazaWAF
-	}
+	// 跨域 允许的来源支持热更新
+	app.Use(middleware.Cors())
+	// 恢复 panic 堆栈写入统一日志
+	app.Use(recover.New(recover.Config{
+		EnableStackTrace: true,
+		StackTraceHandler: func(c *fiber.Ctx, e any) {
+			log.ErrorCtx(c.UserContext(), "panic: ", e, "\n", string(debug.Stack()))
+		},
+	}))
+	// WAF 始终挂载 开关在请求时读取 支持热更新
+	app.Use(middleware.CorazaMiddleware()) // CorazaWAF
 
 	// 路由分组
 	userApi(app.Group("/api/user"))
 	utilApi(app.Group("/api/util"))
 	oauthApi(app.Group("/oauth"))
+	idpApi(app.Group("/oauth2"))
+	app.Get("/.well-known/openid-configuration", idp.IdpApi.Discovery) // OIDC 发现文档
 
 	app.Get("/api/swagger/doc.json", func(c *fiber.Ctx) error {
 		return c.SendFile("./docs/swagger.json")
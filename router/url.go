@@ -1,8 +1,10 @@
 package routers
 
 import (
+	idp "github.com/GoFurry/gofurry-user/apps/idp/controller"
 	oauth "github.com/GoFurry/gofurry-user/apps/oauth/controller"
 	user "github.com/GoFurry/gofurry-user/apps/user/controller"
+	config "github.com/GoFurry/gofurry-user/apps/util/config/controller"
 	email "github.com/GoFurry/gofurry-user/apps/util/email/controller"
 	"github.com/GoFurry/gofurry-user/middleware"
 	"github.com/gofiber/fiber/v2"
@@ -22,6 +24,9 @@ func userApi(g fiber.Router) {
 	//
 	g.Use(middleware.JWTMiddleWare())
 	{
+		g.Post("/token/create", user.UserApi.CreateToken) // 创建个人访问令牌
+		g.Get("/token/list", user.UserApi.ListToken)      // 个人访问令牌列表
+		g.Post("/token/delete", user.UserApi.DeleteToken) // 撤销个人访问令牌
 		//	g.POST("/updateInfo", user.UserApi.UpdateInfo)         // 修改个人信息
 		//	g.POST("/updateEmail", user.UserApi.UpdateEmail)       // 修改邮箱
 		//	g.POST("/updatePassword", user.UserApi.UpdatePassword) // 修改密码
@@ -32,12 +37,28 @@ func userApi(g fiber.Router) {
 }
 
 func oauthApi(g fiber.Router) {
+	g.Get("/login/github", oauth.OauthApi.GithubLogin)       // github 授权跳转
+	g.Get("/login/gitee", oauth.OauthApi.GiteeLogin)         // gitee 授权跳转
 	g.Get("/callback/github", oauth.OauthApi.GithubCallback) // github 三方登录
 	g.Get("/callback/gitee", oauth.OauthApi.GiteeCallback)   // gitee 三方登录
 	//g.Get("/callback/google", oauth.OauthApi.GoogleCallback)
 }
 
+func idpApi(g fiber.Router) {
+	g.Get("/authorize", idp.IdpApi.Authorize)    // 授权端点
+	g.Post("/token", idp.IdpApi.Token)           // 令牌端点
+	g.Get("/userinfo", idp.IdpApi.UserInfo)      // 用户信息
+	g.Post("/userinfo", idp.IdpApi.UserInfo)     // 用户信息
+	g.Post("/revoke", idp.IdpApi.Revoke)         // 令牌撤销
+	g.Post("/introspect", idp.IdpApi.Introspect) // 令牌自省
+	g.Get("/jwks", idp.IdpApi.Jwks)              // 验签公钥
+}
+
 func utilApi(g fiber.Router) {
 	// 邮箱接口
 	g.Get("/email/send", email.EmailApi.Send) // 邮箱验证码
+	// 配置
+	g.Get("/features", config.ConfigApi.Features) // 业务开关
+	g.Get("/config", middleware.JWTMiddleWare(), middleware.RequireScope("config:read"),
+		middleware.RequirePermission("config:read"), config.ConfigApi.Effective) // 生效配置 已脱敏
 }